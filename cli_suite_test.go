@@ -2,7 +2,12 @@ package main_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -21,7 +26,7 @@ var _ = Describe("CLI-API", func() {
 	})
 
 	It("should have --help for all commands", func() {
-		for _, command := range []string{"", "add", "ensure", "dump", "update"} {
+		for _, command := range []string{"", "add", "ensure", "dump", "update", "status", "undo", "history", "init", "bootstrap", "sync"} {
 			cmd := exec.Command("./punkt", command, "--help")
 			expectSuccess(cmd)
 		}
@@ -31,6 +36,56 @@ var _ = Describe("CLI-API", func() {
 		cmd := exec.Command("./punkt", "--version")
 		expectSuccess(cmd)
 	})
+
+	Context("--output", func() {
+		var home string
+
+		BeforeEach(func() {
+			var err error
+			home, err = ioutil.TempDir("", "punkt")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(home)).To(Succeed())
+		})
+
+		run := func(format string) *exec.Cmd {
+			return exec.Command("./punkt", "history", "--output", format,
+				"--config", filepath.Join(home, "config.toml"),
+				"--punkt-home", home,
+				"--dotfiles", home)
+		}
+
+		It("should print the events as a json array", func() {
+			var stdout bytes.Buffer
+			cmd := run("json")
+			cmd.Stdout = &stdout
+			expectSuccess(cmd)
+
+			var events []map[string]interface{}
+			Expect(json.Unmarshal(stdout.Bytes(), &events)).To(Succeed())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0]).To(HaveKeyWithValue("operation", "history"))
+			Expect(events[0]).To(HaveKeyWithValue("result", "success"))
+		})
+
+		It("should print each event on its own line as jsonl", func() {
+			var stdout bytes.Buffer
+			cmd := run("jsonl")
+			cmd.Stdout = &stdout
+			expectSuccess(cmd)
+
+			for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+				var event map[string]interface{}
+				Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+			}
+		})
+
+		It("should fail for an unknown format", func() {
+			Expect(run("yaml").Run()).NotTo(Succeed())
+		})
+	})
 })
 
 func expectSuccess(cmd *exec.Cmd) {
@@ -2,14 +2,19 @@ package punkt
 
 import (
 	"os"
+	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/printer"
 )
 
 var addCmd = &cobra.Command{
 	Use:   "add",
-	Short: "add a symlink or repository",
+	Short: "add a symlink, secret or repository",
 }
 
 var addSymlinkCmd = &cobra.Command{
@@ -21,26 +26,81 @@ see remove.
 
 If you don't specify a new location the new location will default to having the same
 relative path to your dotfiles directory as it currently has to your home directory
-(i.e placing ~/.config/git/ignore in ~/dotfiles/.config/git/ignore).`,
+(i.e placing ~/.config/git/ignore in ~/dotfiles/.config/git/ignore).
+
+With --copy a copy of the file is kept at its location instead of a symlink, for tools
+that replace or won't follow symlinks. Ensure updates the copy when the file in your
+dotfiles changes and dump offers to pull edits made to the copy back into them.`,
 	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		addSymlink(cmd, args)
 	},
 }
 
+var addCopy bool
+
+var addSecretCmd = &cobra.Command{
+	Use:   "secret file [new-location]",
+	Short: "Encrypt the file into your dotfiles and store it as a secret",
+	Long: `Encrypt the file into your dotfiles, at the new location which is optional, and save
+it to your configured symlinks as a secret. When ensuring, secrets are decrypted back to
+where the file is, readable only by you, instead of being symlinked.
+
+Secrets are encrypted with a passphrase, read from the file given by secretKeyFile in
+your configuration or asked for if it isn't set. If you don't specify a new location it
+is inferred the same way as for symlinks, with .asc appended.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		addSecret(cmd, args)
+	},
+}
+
 var addGitCmd = &cobra.Command{
-	Use:   "repository path",
+	Use:   "repository [path|url]",
 	Short: "Add the git repository to the dotfile git configuration",
-	Long:  `Add the target git repository to the configuration file for git repositories.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Add the target git repository to the configuration file for git repositories.
+
+Given the url of a remote repository instead of a path it is cloned, to
+<host>/<owner>/<name> below repositoryRoot in your configuration (~/code by
+default), and added so that it's cloned on your other machines the next time
+they ensure.
+
+With --scan the directory is walked for working copies instead and every one with an
+origin remote that isn't configured yet is added. Working copies aren't walked into, nor
+are directories matching an --ignore pattern, by name or by path relative to the
+directory scanned.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if scanDir != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if scanDir != "" {
+			scanGit(cmd, args)
+			return
+		}
+
 		addGit(cmd, args)
 	},
 }
 
+var scanDir string
+var scanMaxDepth int
+var scanIgnore []string
+
 func init() {
+	addSymlinkCmd.Flags().BoolVar(&addCopy, "copy", false, `Keep a copy of the file instead of a symlink`)
 	addCmd.AddCommand(addSymlinkCmd)
+	addCmd.AddCommand(addSecretCmd)
+	addGitCmd.Flags().StringVar(&scanDir, "scan", "", `Add the working copies found in the directory`)
+	addGitCmd.Flags().IntVar(&scanMaxDepth, "max-depth", 3, `How many directories deep to look when scanning`)
+	addGitCmd.Flags().StringArrayVar(&scanIgnore, "ignore", []string{"node_modules"}, `Pattern of directories not to scan, can be repeated`)
 	addCmd.AddCommand(addGitCmd)
+	addDryRunFlag(addCmd)
+	addConflictFlag(addCmd)
+	addCommitFlag(addCmd)
 	RootCmd.AddCommand(addCmd)
 }
 
@@ -51,18 +111,80 @@ func addSymlink(cmd *cobra.Command, args []string) {
 	}
 
 	mgr := rootMgr.Symlink()
-	_, err := mgr.Add(args[0], newLocation)
+	add := mgr.Add
+	if addCopy {
+		add = mgr.AddCopy
+	}
+
+	start := time.Now()
+	_, err := add(args[0], newLocation)
+	printer.Log.Event(printer.NewEvent("symlink", "add", args[0], start, err))
+	if err == nil {
+		err = commitChanges(cmd, args)
+	}
+	finish(cmd, err)
 	if err != nil {
 		logrus.WithError(err).Error("failed to add symlink")
 		os.Exit(1)
 	}
 }
 
+func addSecret(cmd *cobra.Command, args []string) {
+	newLocation := ""
+	if len(args) == 2 {
+		newLocation = args[1]
+	}
+
+	mgr := rootMgr.Symlink()
+	start := time.Now()
+	_, err := mgr.AddSecret(args[0], newLocation)
+	printer.Log.Event(printer.NewEvent("symlink", "add", args[0], start, err))
+	if err == nil {
+		err = commitChanges(cmd, args)
+	}
+	finish(cmd, err)
+	if err != nil {
+		logrus.WithError(err).Error("failed to add secret")
+		os.Exit(1)
+	}
+}
+
 func addGit(cmd *cobra.Command, args []string) {
 	mgr := rootMgr.Git()
-	err := mgr.Add(args[0])
+	start := time.Now()
+	var err error
+	if git.IsURL(args[0]) {
+		_, err = mgr.AddURL(args[0])
+	} else {
+		err = mgr.Add(args[0])
+	}
+	printer.Log.Event(printer.NewEvent("git", "add", args[0], start, err))
+	if err == nil {
+		err = commitChanges(cmd, args)
+	}
+	finish(cmd, err)
 	if err != nil {
 		logrus.WithError(err).Error("failed to add git repo")
 		os.Exit(1)
 	}
 }
+
+func scanGit(cmd *cobra.Command, args []string) {
+	mgr := rootMgr.Git()
+	start := time.Now()
+	added, err := mgr.Scan(scanDir, scanMaxDepth, scanIgnore)
+	for _, path := range added {
+		printer.Log.Event(printer.NewEvent("git", "add", path, start, nil))
+	}
+	if err != nil {
+		printer.Log.Event(printer.NewEvent("git", "scan", scanDir, start, err))
+	}
+	if len(added) > 0 {
+		err = multierror.Append(err, commitChanges(cmd, []string{"--scan", scanDir})).ErrorOrNil()
+	}
+	finish(cmd, err)
+	if err != nil {
+		logrus.WithError(err).Error("failed to add git repos")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package punkt
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var bootstrapLongMsg = strings.TrimSpace(`
+Bootstrap a clean machine from your dotfiles repository.
+
+Clones the repository, which can be any URL git understands or a local
+path, into your dotfiles directory. The punkt configuration is then looked
+for in the repository, in .config/punkt, punkt or at its root, and ensure
+is run for all managers configured there. If your punkt home isn't set up
+yet the directory the configuration was found in is used instead.`)
+
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap repository",
+	Short: "Set up your environment from a dotfiles repository",
+	Long:  bootstrapLongMsg,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bootstrap(cmd, args)
+	},
+}
+
+func init() {
+	addDryRunFlag(bootstrapCmd)
+	addConflictFlag(bootstrapCmd)
+	addJobsFlag(bootstrapCmd)
+	RootCmd.AddCommand(bootstrapCmd)
+}
+
+func bootstrap(cmd *cobra.Command, args []string) {
+	url := args[0]
+	if abs, err := snapshot.AsAbsolute(url); err == nil {
+		url = abs
+	}
+
+	err := rootMgr.Bootstrap(url)
+	finish(cmd, err)
+	if err != nil {
+		os.Exit(1)
+	}
+}
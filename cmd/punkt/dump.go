@@ -11,8 +11,9 @@ var message = strings.TrimSpace(`
 Dump the current working environment to your dotfile configuration.
 
 Goes through all your specified managers and for each of these dumping
-their configuration to their specific configuration files. This should
-be free of side effects.`)
+their configuration to their specific configuration files. Apart from
+offering to pull local edits to copied files back into your dotfiles this
+should be free of side effects.`)
 
 // ensureCmd represents the ensure command
 var dumpCmd = &cobra.Command{
@@ -30,6 +31,7 @@ func init() {
 
 func dump(cmd *cobra.Command, args []string) {
 	err := rootMgr.Dump(rootMgr.All())
+	finish(cmd, err)
 	if err != nil {
 		os.Exit(1)
 	}
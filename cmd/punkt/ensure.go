@@ -12,23 +12,29 @@ Ensure that your environment is up to to date with what is actually
 configured in your dotfiles.
 
 Goes through each of your manager's configuration files and running
-ensure for each of them.`)
+ensure for each of them. A manager is run after those given by after in
+its configuration in managers.toml, managers that don't depend on each
+other are run at the same time, at most --jobs of them at once.`)
 
 var ensureCmd = &cobra.Command{
 	Use:   "ensure",
 	Short: "Ensure your environment is up to date with your dotfiles",
 	Long:  ensureLongMsg,
 	Run: func(cmd *cobra.Command, args []string) {
-		ensure()
+		ensure(cmd)
 	},
 }
 
 func init() {
+	addDryRunFlag(ensureCmd)
+	addConflictFlag(ensureCmd)
+	addJobsFlag(ensureCmd)
 	RootCmd.AddCommand(ensureCmd)
 }
 
-func ensure() {
+func ensure(cmd *cobra.Command) {
 	err := rootMgr.Ensure(rootMgr.All())
+	finish(cmd, err)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -0,0 +1,62 @@
+package punkt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the runs stored in the journal",
+	Long: `List the runs of punkt that have been recorded in the journal, oldest
+first, along with the id to use to undo them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		history(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+}
+
+func history(cmd *cobra.Command) {
+	runs, err := runJournal.Runs()
+	if err != nil {
+		printer.Log.Error("unable to read the journal: <fg 1>%s", err)
+		logrus.WithError(err).Error("unable to read journal")
+		report(cmd, err)
+		os.Exit(1)
+	}
+
+	defer report(cmd, nil)
+	if len(runs) == 0 {
+		printer.Log.Note("no runs recorded in the journal")
+		return
+	}
+
+	for _, run := range runs {
+		msg := "<fg 5>%s<reset> %s: %d operations"
+		if run.Undone {
+			msg += " <fg 3>(undone)"
+		}
+
+		printer.Log.Note(msg, run.ID, run.Command, len(run.Entries))
+
+		event := printer.Event{
+			Operation: "history",
+			Item:      run.ID,
+			Result:    printer.ResultSuccess,
+			Message:   fmt.Sprintf("%s: %d operations", run.Command, len(run.Entries)),
+		}
+		if run.Undone {
+			event.Message += " (undone)"
+		}
+		printer.Log.Event(event)
+	}
+}
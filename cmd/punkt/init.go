@@ -0,0 +1,46 @@
+package punkt
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var initLongMsg = strings.TrimSpace(`
+Set up punkt by creating its configuration and your dotfiles repository.
+
+Creates your punkt home with a commented config.toml, which is written to
+the file given by --config, and empty configuration files for managers,
+symlinks and git. Your dotfiles directory is initialised as a git
+repository. Anything that already exists is left as is, so it is safe to
+run init again.
+
+With --adopt you are offered the common dotfiles found in your home
+directory to move into your dotfiles and symlink back.`)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up punkt home and your dotfiles repository",
+	Long:  initLongMsg,
+	Run: func(cmd *cobra.Command, args []string) {
+		initialise(cmd)
+	},
+}
+
+var initAdopt bool
+
+func init() {
+	initCmd.Flags().BoolVar(&initAdopt, "adopt", false, `Pick common dotfiles in your home directory to adopt`)
+	addDryRunFlag(initCmd)
+	addConflictFlag(initCmd)
+	RootCmd.AddCommand(initCmd)
+}
+
+func initialise(cmd *cobra.Command) {
+	err := rootMgr.Init(snapshot.ExpandHome(configFile), initAdopt)
+	finish(cmd, err)
+	if err != nil {
+		os.Exit(1)
+	}
+}
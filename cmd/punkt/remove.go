@@ -2,9 +2,12 @@ package punkt
 
 import (
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/mbark/punkt/pkg/printer"
 )
 
 var removeCmd = &cobra.Command{
@@ -24,10 +27,11 @@ removes the symlik and moves the file back to its original position.`,
 }
 
 var removeGitCmd = &cobra.Command{
-	Use:   "repository",
+	Use:   "repository path",
 	Short: "Remove the git repository from your dotfiles",
-	Long:  `Remove the git repository from your dotfiles' git configuration file`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Remove the git repository from your dotfiles' git configuration file, the path can
+be relative, start with ~ or be absolute.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		removeGit(cmd, args)
 	},
@@ -36,12 +40,20 @@ var removeGitCmd = &cobra.Command{
 func init() {
 	removeCmd.AddCommand(removeSymlinkCmd)
 	removeCmd.AddCommand(removeGitCmd)
+	addDryRunFlag(removeCmd)
+	addCommitFlag(removeCmd)
 	RootCmd.AddCommand(removeCmd)
 }
 
 func removeSymlink(cmd *cobra.Command, args []string) {
 	mgr := rootMgr.Symlink()
+	start := time.Now()
 	err := mgr.Remove(args[0])
+	printer.Log.Event(printer.NewEvent("symlink", "remove", args[0], start, err))
+	if err == nil {
+		err = commitChanges(cmd, args)
+	}
+	finish(cmd, err)
 	if err != nil {
 		logrus.WithError(err).Error("unable to remove symlink")
 		os.Exit(1)
@@ -50,7 +62,13 @@ func removeSymlink(cmd *cobra.Command, args []string) {
 
 func removeGit(cmd *cobra.Command, args []string) {
 	mgr := rootMgr.Git()
+	start := time.Now()
 	err := mgr.Remove(args[0])
+	printer.Log.Event(printer.NewEvent("git", "remove", args[0], start, err))
+	if err == nil {
+		err = commitChanges(cmd, args)
+	}
+	finish(cmd, err)
 	if err != nil {
 		logrus.WithError(err).Error("unable to remove git repository")
 		os.Exit(1)
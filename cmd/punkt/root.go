@@ -3,8 +3,10 @@ package punkt
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/reconquest/loreley"
@@ -15,7 +17,12 @@ import (
 
 	"github.com/mbark/punkt/pkg/conf"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/journal"
 	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
 )
 
 var (
@@ -23,11 +30,22 @@ var (
 	configFile string
 	punktHome  string
 	dotfiles   string
+	dryRun     bool
+	conflict   string
+	stash      bool
+	force      bool
+	commit     bool
+	jobs       int
+	output     string
 )
 
 var config *conf.Config
 var snapshot *fs.Snapshot
 var rootMgr mgr.RootManager
+var dryRunPlan *plan.Plan
+var runJournal journal.Journal
+var journalRun *journal.Run
+var started time.Time
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -68,6 +86,7 @@ func init() {
 	RootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "info", `Set the logging level ("debug"|"info"|"warn"|"error"|"fatal")`)
 	RootCmd.PersistentFlags().StringVarP(&punktHome, "punkt-home", "p", punktHome, `Where all punkt configuration files should be stored`)
 	RootCmd.PersistentFlags().StringVarP(&dotfiles, "dotfiles", "d", dotfiles, `The directory containing the user's dotfiles`)
+	RootCmd.PersistentFlags().StringVarP(&output, "output", "o", printer.FormatText, `Print the output as text or as structured events ("text"|"json"|"jsonl")`)
 
 	var result error
 	err = viper.BindPFlag("logLevel", RootCmd.PersistentFlags().Lookup("log-level"))
@@ -91,14 +110,105 @@ func init() {
 }
 
 func initConfig() {
-	var err error
+	started = time.Now()
+	err := printer.Log.SetFormat(output)
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid output format")
+		os.Exit(1)
+	}
+
+	if printer.Log.Structured() {
+		run.Out = os.Stderr
+	}
+
 	config, err = conf.NewConfig(*snapshot, snapshot.ExpandHome(configFile))
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to red configuration file")
 		os.Exit(1)
 	}
 
+	if conflict != "" {
+		config.Conflict = conflict
+	}
+
+	if stash {
+		config.Dirty = git.DirtyStash
+	}
+	if force {
+		config.Dirty = git.DirtyForce
+	}
+
+	runJournal = journal.New(*snapshot, filepath.Join(config.PunktHome, "journal"))
+	if dryRun {
+		dryRunPlan = plan.New()
+		snapshot.Fs = plan.NewFilesystem(dryRunPlan, snapshot.Fs)
+		run.ReadOnly = run.Commander
+		run.Commander = dryRunPlan.Commander
+	} else {
+		journalRun = runJournal.Start()
+		snapshot.Fs = journal.NewFilesystem(journalRun, snapshot.Fs)
+	}
+
 	rootMgr = *mgr.NewRootManager(*config, *snapshot)
+	rootMgr.Plan = dryRunPlan
+	rootMgr.Jobs = jobs
+}
+
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false, `Print what would be done without changing anything`)
+}
+
+func addConflictFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&conflict, "conflict", "", `How to handle existing files in the way of a symlink without its own strategy ("skip"|"backup"|"overwrite"|"adopt")`)
+}
+
+func addJobsFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", 1, `How many managers to run at the same time`)
+}
+
+func addCommitFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&commit, "commit", false, `Commit the changes to your dotfiles and sync them with their remote`)
+}
+
+// commitChanges syncs the dotfiles if --commit was given, describing the
+// changes as the command that was run.
+func commitChanges(cmd *cobra.Command, args []string) error {
+	if !commit {
+		return nil
+	}
+
+	return rootMgr.Sync(strings.Join(append([]string{operation(cmd)}, args...), " "))
+}
+
+// operation returns the command as it was run, without punkt
+func operation(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), RootCmd.Name()+" ")
+}
+
+// finish should be called once a command has made its changes with the
+// error it failed with, if any. It prints the plan when doing a dry run and
+// otherwise saves the run to the journal so that it can be undone, and then
+// reports the result.
+func finish(cmd *cobra.Command, result error) {
+	defer report(cmd, result)
+	if dryRunPlan != nil {
+		dryRunPlan.Print()
+		return
+	}
+
+	journalRun.Command = operation(cmd)
+	err := runJournal.Save(journalRun)
+	if err != nil {
+		printer.Log.Error("failed to save the run to the journal: <fg 1>%s", err)
+		logrus.WithError(err).Error("failed to save journal")
+	}
+}
+
+// report emits the event for the command with its result and prints the
+// events kept for json output, it should be called last by every command.
+func report(cmd *cobra.Command, err error) {
+	printer.Log.Event(printer.NewEvent("", operation(cmd), "", started, err))
+	printer.Log.Flush()
 }
 
 func compileUsage() string {
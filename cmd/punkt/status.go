@@ -0,0 +1,38 @@
+package punkt
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var statusLongMsg = strings.TrimSpace(`
+Report how your environment differs from what is configured in your
+dotfiles, i.e. what running ensure would change.
+
+Goes through each of your managers and reports symlinks that are missing,
+broken or pointing somewhere else, git repositories that are missing,
+dirty or behind their remote and managers whose dump differs from the
+stored configuration. Exits with a non-zero status if anything differs.`)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report what differs between your environment and your dotfiles",
+	Long:  statusLongMsg,
+	Run: func(cmd *cobra.Command, args []string) {
+		status(cmd)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statusCmd)
+}
+
+func status(cmd *cobra.Command) {
+	drifts, err := rootMgr.Status(rootMgr.All())
+	report(cmd, err)
+	if err != nil || len(drifts) > 0 {
+		os.Exit(1)
+	}
+}
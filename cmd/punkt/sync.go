@@ -0,0 +1,46 @@
+package punkt
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var syncLongMsg = strings.TrimSpace(`
+Commit the changes in your dotfiles and sync them with their remote.
+
+All changes in your dotfiles directory are committed, with the message
+describing them. If your punkt home is inside your dotfiles only its
+configuration files are, not the state punkt keeps there. The dotfiles
+are then pulled from and pushed to their remote. If both your dotfiles
+and the remote have new commits yours are rebased onto the remote's, if
+they conflict nothing is pulled or pushed, resolve the conflict with git
+and sync again.
+
+The add and remove commands can do the same for you with --commit.`)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Commit your dotfiles and sync them with their remote",
+	Long:  syncLongMsg,
+	Run: func(cmd *cobra.Command, args []string) {
+		syncDotfiles(cmd)
+	},
+}
+
+var syncMessage string
+
+func init() {
+	syncCmd.Flags().StringVarP(&syncMessage, "message", "m", "sync", `What to describe the changes as in the commit message`)
+	addDryRunFlag(syncCmd)
+	RootCmd.AddCommand(syncCmd)
+}
+
+func syncDotfiles(cmd *cobra.Command) {
+	err := rootMgr.Sync(syncMessage)
+	finish(cmd, err)
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package punkt
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/mbark/punkt/pkg/journal"
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+var undoLongMsg = strings.TrimSpace(`
+Undo the changes made by a previous run of punkt.
+
+Every file moved, symlinked, removed or written by punkt is recorded in a
+journal in your punkt home. Undo reverts the operations of the last run
+that hasn't already been undone, or of the run with the given id, in
+reverse order. See history for the available runs.`)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [run-id]",
+	Short: "Revert the changes made by the last run, or the given one",
+	Long:  undoLongMsg,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		undo(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(undoCmd)
+}
+
+func undo(cmd *cobra.Command, args []string) {
+	var run *journal.Run
+	var err error
+	if len(args) == 1 {
+		run, err = runJournal.Get(args[0])
+	} else {
+		run, err = runJournal.Last()
+	}
+
+	if err != nil {
+		printer.Log.Error("unable to find run to undo: <fg 1>%s", err)
+		logrus.WithError(err).Error("unable to find run to undo")
+		report(cmd, err)
+		os.Exit(1)
+	}
+
+	printer.Log.Start("undo", "undoing <fg 2>%s<reset> run <fg 5>%s", run.Command, run.ID)
+	start := time.Now()
+	err = runJournal.Undo(run)
+	printer.Log.Event(printer.NewEvent("", "undo", run.ID, start, err))
+	if err != nil {
+		printer.Log.Error("undo did not successfully revert all operations: <fg 1>%s", err)
+		logrus.WithError(err).Error("failed to undo run")
+		report(cmd, err)
+		os.Exit(1)
+	}
+
+	printer.Log.Done("undo", "reverted %d operations", len(run.Entries))
+	report(cmd, nil)
+}
@@ -2,7 +2,10 @@ package punkt
 
 import (
 	"os"
+	"strings"
 
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -10,20 +13,87 @@ var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Run update for all managers",
 	Long: `Goes through all managers running update for each of them and
-also potentially updating their configuration.`,
+also potentially updating their configuration.
+
+Git repositories pinned to a branch pull that branch while those pinned to
+a tag or commit stay where they are. To move a pin give --bump path=ref,
+which sets the repository's pin to ref, keeping whether it is a branch, tag
+or commit, before updating. Shallow repositories are kept shallow, give
+--unshallow path to fetch a repository's whole history and stop cloning it
+shallow.
+
+Git repositories with local changes or untracked files aren't updated,
+what they have is reported instead. With --stash the changes are stashed
+and applied again after pulling, with --force they are discarded and the
+untracked files removed.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		update()
+		update(cmd)
 	},
 }
 
+var bumps, unshallow []string
+
 func init() {
+	updateCmd.Flags().StringArrayVar(&bumps, "bump", nil, `Move the pin of the git repository at path to ref, given as path=ref`)
+	updateCmd.Flags().StringArrayVar(&unshallow, "unshallow", nil, `Fetch the whole history of the shallow git repository at path`)
+	updateCmd.Flags().BoolVar(&stash, "stash", false, `Stash the local changes of git repositories while updating them`)
+	updateCmd.Flags().BoolVar(&force, "force", false, `Discard the local changes and untracked files of git repositories when updating them`)
+	addDryRunFlag(updateCmd)
+	addJobsFlag(updateCmd)
 	RootCmd.AddCommand(updateCmd)
 }
 
 // Update ...
-func update() {
-	err := rootMgr.Update(rootMgr.All())
+func update(cmd *cobra.Command) {
+	var err error
+	if stash && force {
+		err = errors.New("--stash and --force can't be combined")
+	}
+	if err == nil {
+		err = bump()
+	}
+	if err == nil {
+		err = unshallowRepos()
+	}
+	if err == nil {
+		err = rootMgr.Update(rootMgr.All())
+	}
+
+	finish(cmd, err)
 	if err != nil {
 		os.Exit(1)
 	}
 }
+
+// bump moves the pins given with --bump
+func bump() error {
+	var result error
+	for _, b := range bumps {
+		parts := strings.SplitN(b, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			result = multierror.Append(result, errors.Errorf("invalid bump %s, expected path=ref", b))
+			continue
+		}
+
+		err := rootMgr.Git().Bump(parts[0], parts[1])
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "unable to bump %s", parts[0]))
+		}
+	}
+
+	return result
+}
+
+// unshallowRepos fetches the whole history of the repositories given with
+// --unshallow
+func unshallowRepos() error {
+	var result error
+	for _, path := range unshallow {
+		err := rootMgr.Git().Unshallow(path)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "unable to unshallow %s", path))
+		}
+	}
+
+	return result
+}
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"os"
 	"path/filepath"
 	"time"
 
@@ -16,7 +17,22 @@ import (
 type Config struct {
 	PunktHome string
 	Dotfiles  string
-	Managers  map[string]map[string]string
+	// Conflict is the strategy used when a file is in the way of a symlink
+	// and the symlink doesn't specify its own.
+	Conflict string
+	// Dirty is how git repositories with local changes are updated
+	Dirty string
+	// RepositoryRoot is where repositories added by their url are cloned
+	RepositoryRoot string
+	// Variables are custom values that templates can be rendered with
+	Variables map[string]string
+	// SecretKeyFile contains the passphrase for secrets, if empty the
+	// passphrase is asked for when needed.
+	SecretKeyFile string
+	Managers      map[string]map[string]string
+	// Dependencies are the managers each manager is run after, given by
+	// after in its configuration.
+	Dependencies map[string][]string
 }
 
 // NewConfig builds a new configuration object from the given parameters
@@ -29,22 +45,33 @@ func NewConfig(snapshot fs.Snapshot, file string) (*Config, error) {
 	setLogLevel()
 	configureLogFiles()
 
-	mgrs, err := readManagers(snapshot)
+	mgrs, dependencies, err := readManagers(snapshot)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Config{
-		PunktHome: viper.GetString("punktHome"),
-		Dotfiles:  viper.GetString("dotfiles"),
-		Managers:  mgrs,
+		PunktHome:      viper.GetString("punktHome"),
+		Dotfiles:       viper.GetString("dotfiles"),
+		Conflict:       viper.GetString("conflict"),
+		Dirty:          viper.GetString("dirty"),
+		RepositoryRoot: viper.GetString("repositoryRoot"),
+		Variables:      viper.GetStringMapString("variables"),
+		SecretKeyFile:  viper.GetString("secretKeyFile"),
+		Managers:       mgrs,
+		Dependencies:   dependencies,
 	}, nil
 }
 
+// readConfig reads the given file into viper, a missing file isn't an error
+// as the defaults can be used until punkt init has created one.
 func readConfig(snapshot fs.Snapshot, file string) error {
 	abs, err := snapshot.AsAbsolute(file)
-	if err != nil {
-		return errors.Wrapf(err, "given config file %s does not exist", file)
+	if os.IsNotExist(err) {
+		printer.Log.Note("no configuration at <fg 5>%s<reset>, using the defaults", snapshot.UnexpandHome(abs))
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "given config file %s can't be read", file)
 	}
 
 	printer.Log.Note("reading configuration from <fg 5>%s", snapshot.UnexpandHome(abs))
@@ -92,14 +119,45 @@ func configureLogFiles() {
 	}
 }
 
-func readManagers(snapshot fs.Snapshot) (map[string]map[string]string, error) {
+// readManagers reads the managers' configuration, separating out the list
+// of managers each of them is to run after.
+func readManagers(snapshot fs.Snapshot) (map[string]map[string]string, map[string][]string, error) {
 	path := filepath.Join(viper.GetString("punktHome"), "managers.toml")
-	var mgrs map[string]map[string]string
+	var raw map[string]map[string]interface{}
+
+	err := snapshot.ReadToml(&raw, path)
+	if err == fs.ErrNoSuchFile {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to read manager configuration")
+	}
 
-	err := snapshot.ReadToml(&mgrs, path)
-	if err != nil && err != fs.ErrNoSuchFile {
-		return nil, errors.Wrapf(err, "unable to read manager configuration")
+	mgrs := make(map[string]map[string]string)
+	dependencies := make(map[string][]string)
+	for name, settings := range raw {
+		mgrs[name] = make(map[string]string)
+		for key, val := range settings {
+			switch v := val.(type) {
+			case string:
+				if key == "after" {
+					dependencies[name] = []string{v}
+				} else {
+					mgrs[name][key] = v
+				}
+			case []interface{}:
+				for _, dep := range v {
+					s, ok := dep.(string)
+					if key != "after" || !ok {
+						return nil, nil, errors.Errorf("%s.%s in %s should be a string", name, key, path)
+					}
+
+					dependencies[name] = append(dependencies[name], s)
+				}
+			default:
+				return nil, nil, errors.Errorf("%s.%s in %s should be a string", name, key, path)
+			}
+		}
 	}
 
-	return mgrs, nil
+	return mgrs, dependencies, nil
 }
@@ -31,6 +31,10 @@ var _ = Describe("Manager", func() {
 		savedConfig["logLevel"] = "warn"
 		savedConfig["dotfiles"] = "/some/where"
 		savedConfig["punktHome"] = "/punkt/.home"
+		savedConfig["conflict"] = "backup"
+		savedConfig["dirty"] = "stash"
+		savedConfig["repositoryRoot"] = "~/src"
+		savedConfig["secretKeyFile"] = "~/.punkt.key"
 		err := snapshot.SaveToml(savedConfig, configFile)
 		Expect(err).To(BeNil())
 	})
@@ -43,6 +47,23 @@ var _ = Describe("Manager", func() {
 		Expect(logrus.GetLevel()).To(Equal(logrus.WarnLevel))
 		Expect(config.Dotfiles).To(Equal(savedConfig["dotfiles"]))
 		Expect(config.PunktHome).To(Equal(savedConfig["punktHome"]))
+		Expect(config.Conflict).To(Equal(savedConfig["conflict"]))
+		Expect(config.Dirty).To(Equal(savedConfig["dirty"]))
+		Expect(config.RepositoryRoot).To(Equal(savedConfig["repositoryRoot"]))
+		Expect(config.SecretKeyFile).To(Equal(savedConfig["secretKeyFile"]))
+	})
+
+	It("should read the variables for templates", func() {
+		withVariables := map[string]interface{}{
+			"punktHome": "/punkt/.home",
+			"variables": map[string]string{"email": "me@example.com"},
+		}
+		Expect(snapshot.SaveToml(withVariables, configFile)).To(Succeed())
+
+		config, err := conf.NewConfig(snapshot, configFile)
+
+		Expect(err).To(BeNil())
+		Expect(config.Variables).To(Equal(map[string]string{"email": "me@example.com"}))
 	})
 
 	It("should handle when a relative file is given", func() {
@@ -58,6 +79,13 @@ var _ = Describe("Manager", func() {
 		Expect(config.PunktHome).To(Equal(savedConfig["punktHome"]))
 	})
 
+	It("should use the defaults if the config file doesn't exist", func() {
+		config, err := conf.NewConfig(snapshot, filepath.Join(snapshot.WorkingDir, "missing.toml"))
+
+		Expect(err).To(BeNil())
+		Expect(config).NotTo(BeNil())
+	})
+
 	It("should set a default for loglevel", func() {
 		savedConfig["logLevel"] = "mumbojumbo"
 		err := snapshot.SaveToml(savedConfig, configFile)
@@ -80,4 +108,28 @@ var _ = Describe("Manager", func() {
 		Expect(config.Managers).To(Equal(mgrs))
 		Expect(err).To(BeNil())
 	})
+
+	It("should read what managers are run after from managers.toml", func() {
+		content := "[foo]\ncommand = \"bar\"\nafter = [\"git\", \"baz\"]\n\n[baz]\ncommand = \"baz\"\nafter = \"symlink\"\n"
+		Expect(snapshot.Save(content, filepath.Join(savedConfig["punktHome"], "managers.toml"))).To(Succeed())
+
+		config, err := conf.NewConfig(snapshot, configFile)
+		Expect(err).To(BeNil())
+		Expect(config.Managers).To(Equal(map[string]map[string]string{
+			"foo": {"command": "bar"},
+			"baz": {"command": "baz"},
+		}))
+		Expect(config.Dependencies).To(Equal(map[string][]string{
+			"foo": {"git", "baz"},
+			"baz": {"symlink"},
+		}))
+	})
+
+	It("should fail if a manager is configured with something other than strings", func() {
+		content := "[foo]\ncommand = 1\n"
+		Expect(snapshot.Save(content, filepath.Join(savedConfig["punktHome"], "managers.toml"))).To(Succeed())
+
+		_, err := conf.NewConfig(snapshot, configFile)
+		Expect(err).NotTo(BeNil())
+	})
 })
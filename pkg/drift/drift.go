@@ -0,0 +1,24 @@
+package drift
+
+import (
+	"fmt"
+)
+
+// Drift describes something in the current environment that doesn't match
+// what is configured in the dotfiles, i.e. something ensure would change.
+type Drift struct {
+	Item   string
+	Reason string
+}
+
+// New ...
+func New(item, reason string, args ...interface{}) Drift {
+	return Drift{
+		Item:   item,
+		Reason: fmt.Sprintf(reason, args...),
+	}
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s", d.Item, d.Reason)
+}
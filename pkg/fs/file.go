@@ -2,6 +2,8 @@ package fs
 
 import (
 	"io"
+	"io/ioutil"
+	"os"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
@@ -65,6 +67,67 @@ func (snapshot Snapshot) Save(content string, file string) error {
 	return err
 }
 
+// discarder is implemented by filesystems that keep a copy of the files
+// removed through them, Discard removes a file without keeping one.
+type discarder interface {
+	Discard(filename string) error
+}
+
+// SavePrivate saves the content to the file so that only the user can read
+// it. An existing file is removed first to make sure its permissions are
+// replaced as well, without keeping a copy of it anywhere.
+func (snapshot Snapshot) SavePrivate(content []byte, file string) error {
+	err := snapshot.CreateNecessaryDirectories(file)
+	if err != nil {
+		return err
+	}
+
+	if _, err = snapshot.Fs.Lstat(file); err == nil {
+		remove := snapshot.Fs.Remove
+		if d, ok := snapshot.Fs.(discarder); ok {
+			remove = d.Discard
+		}
+
+		err = remove(file)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", file)
+		}
+	}
+
+	f, err := snapshot.Fs.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", file)
+	}
+
+	defer close(f)
+
+	_, err = f.Write(content)
+	return err
+}
+
+// Read the content of the given file, returning ErrNoSuchFile if it doesn't
+// exist
+func (snapshot Snapshot) Read(file string) (string, error) {
+	_, err := snapshot.Fs.Stat(file)
+	if err != nil {
+		return "", ErrNoSuchFile
+	}
+
+	f, err := snapshot.Fs.Open(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %s", file)
+	}
+
+	defer close(f)
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", file)
+	}
+
+	return string(content), nil
+}
+
 func (snapshot Snapshot) ensureFile(file string) (billy.File, error) {
 	err := snapshot.CreateNecessaryDirectories(file)
 	if err != nil {
@@ -49,6 +49,21 @@ var _ = Describe("Fs Manager", func() {
 		})
 	})
 
+	Context("Read", func() {
+		It("should return no such file if the file doesn't exist", func() {
+			_, err := snapshot.Read("/non/existent")
+			Expect(err).To(Equal(fs.ErrNoSuchFile))
+		})
+
+		It("should return what was saved", func() {
+			Expect(snapshot.Save("foo", "/foo/bar")).To(Succeed())
+
+			content, err := snapshot.Read("/foo/bar")
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("foo"))
+		})
+	})
+
 	Context("Save{,Toml}", func() {
 		It("should fail to save if necessary directories can't be made", func() {
 			_, err := snapshot.Fs.Create("/foo")
@@ -67,4 +82,19 @@ var _ = Describe("Fs Manager", func() {
 		})
 	})
 
+	Context("SavePrivate", func() {
+		It("should only let the user read the file", func() {
+			Expect(snapshot.Save("old", "/foo/bar")).To(Succeed())
+			Expect(snapshot.SavePrivate([]byte("secret"), "/foo/bar")).To(Succeed())
+
+			content, err := snapshot.Read("/foo/bar")
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("secret"))
+
+			fi, err := snapshot.Fs.Stat("/foo/bar")
+			Expect(err).To(BeNil())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+
 })
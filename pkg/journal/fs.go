@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+
+	billy "gopkg.in/src-d/go-billy.v4"
+)
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+// filesystem wraps a billy.Filesystem, recording every modification made
+// through it in the run.
+type filesystem struct {
+	billy.Filesystem
+	run *Run
+}
+
+// NewFilesystem returns a filesystem that records all modifications made to
+// it in the given run. Chrooted filesystems are not recorded, they are used
+// for git's internal storage which isn't meaningful to undo file by file.
+func NewFilesystem(run *Run, fs billy.Filesystem) billy.Filesystem {
+	return &filesystem{
+		Filesystem: fs,
+		run:        run,
+	}
+}
+
+func (fs *filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&writeFlags == 0 {
+		return fs.Filesystem.OpenFile(filename, flag, perm)
+	}
+
+	entry := Entry{Kind: Write, Path: filename}
+	if fi, err := fs.Filesystem.Stat(filename); err == nil && fi.Mode().IsRegular() {
+		backup, err := fs.run.backup(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Backup = backup
+	}
+
+	f, err := fs.Filesystem.OpenFile(filename, flag, perm)
+	if err == nil {
+		fs.run.Record(entry)
+	}
+
+	return f, err
+}
+
+func (fs *filesystem) Rename(from, to string) error {
+	err := fs.Filesystem.Rename(from, to)
+	if err == nil {
+		fs.run.Record(Entry{Kind: Move, Path: from, Target: to})
+	}
+
+	return err
+}
+
+func (fs *filesystem) Remove(filename string) error {
+	entry := Entry{Kind: Remove, Path: filename}
+
+	fi, err := fs.Filesystem.Lstat(filename)
+	if err == nil {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			entry.Target, err = fs.Filesystem.Readlink(filename)
+		} else if fi.Mode().IsRegular() {
+			entry.Backup, err = fs.run.backup(filename)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fs.Filesystem.Remove(filename)
+	if err == nil {
+		fs.run.Record(entry)
+	}
+
+	return err
+}
+
+// Discard removes the file without keeping a backup of it, used for files that
+// shouldn't be copied anywhere, such as decrypted secrets.
+func (fs *filesystem) Discard(filename string) error {
+	err := fs.Filesystem.Remove(filename)
+	if err == nil {
+		fs.run.Record(Entry{Kind: Discard, Path: filename})
+	}
+
+	return err
+}
+
+func (fs *filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	var missing []string
+	for dir := filename; ; dir = filepath.Dir(dir) {
+		if _, err := fs.Filesystem.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+
+		missing = append(missing, dir)
+	}
+
+	err := fs.Filesystem.MkdirAll(filename, perm)
+	if err == nil {
+		for i := len(missing) - 1; i >= 0; i-- {
+			fs.run.Record(Entry{Kind: Mkdir, Path: missing[i]})
+		}
+	}
+
+	return err
+}
+
+func (fs *filesystem) Symlink(target, link string) error {
+	err := fs.Filesystem.Symlink(target, link)
+	if err == nil {
+		fs.run.Record(Entry{Kind: Symlink, Path: link, Target: target})
+	}
+
+	return err
+}
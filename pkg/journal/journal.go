@@ -0,0 +1,287 @@
+package journal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-billy.v4/util"
+
+	"github.com/mbark/punkt/pkg/fs"
+)
+
+// ErrNoRuns is returned when there is no run in the journal to undo
+var ErrNoRuns = errors.New("no runs found in the journal")
+
+// The kinds of operations recorded in the journal
+const (
+	Move    = "move"
+	Symlink = "symlink"
+	Remove  = "remove"
+	Write   = "write"
+	Mkdir   = "mkdir"
+	Discard = "discard"
+)
+
+// Entry is a single operation performed during a run. Path is the file
+// operated on, Target is where it was moved or what it links to and Backup
+// is where the previous content of the file is kept, if there was any.
+type Entry struct {
+	Kind   string
+	Path   string
+	Target string
+	Backup string
+}
+
+func (e Entry) String() string {
+	if e.Target == "" {
+		return fmt.Sprintf("%s %s", e.Kind, e.Path)
+	}
+
+	return fmt.Sprintf("%s %s -> %s", e.Kind, e.Path, e.Target)
+}
+
+// Run is all operations performed by a single invocation of punkt
+type Run struct {
+	ID      string
+	Command string
+	Time    time.Time
+	Undone  bool
+	Entries []Entry
+
+	mutex   *sync.Mutex
+	backups int
+	journal Journal
+}
+
+// Journal stores runs in a directory, making it possible to list and undo
+// them afterwards.
+type Journal struct {
+	snapshot fs.Snapshot
+	dir      string
+}
+
+// New creates a journal stored in dir. The given snapshot should not be
+// journaled itself, it is used to read and write the journal.
+func New(snapshot fs.Snapshot, dir string) Journal {
+	return Journal{
+		snapshot: snapshot,
+		dir:      dir,
+	}
+}
+
+// Start a new run, the command it is for can be set afterwards
+func (j Journal) Start() *Run {
+	now := time.Now()
+	return &Run{
+		ID:      now.Format("20060102-150405.000000000"),
+		Time:    now,
+		mutex:   new(sync.Mutex),
+		journal: j,
+	}
+}
+
+// Record the entry as having been performed
+func (run *Run) Record(entry Entry) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	logrus.WithField("entry", entry).Debug("recording entry in journal")
+	run.Entries = append(run.Entries, entry)
+}
+
+// backup copies the current content of the file so that it can be restored
+// if the run is undone, returning where it was copied to.
+func (run *Run) backup(file string) (string, error) {
+	run.mutex.Lock()
+	run.backups++
+	dir := filepath.Join(run.journal.dir, run.ID)
+	backup := filepath.Join(dir, fmt.Sprintf("%d", run.backups))
+	run.mutex.Unlock()
+
+	err := run.journal.snapshot.Fs.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create %s", dir)
+	}
+
+	return backup, copyFile(run.journal.snapshot, file, backup)
+}
+
+// Save the run to the journal, runs without any entries are not saved
+func (j Journal) Save(run *Run) error {
+	if len(run.Entries) == 0 {
+		logrus.WithField("run", run.ID).Debug("nothing recorded, not saving run")
+		return nil
+	}
+
+	return j.snapshot.SaveToml(run, j.file(run.ID))
+}
+
+// Runs returns all runs in the journal, oldest first
+func (j Journal) Runs() ([]Run, error) {
+	files, err := util.Glob(j.snapshot.Fs, filepath.Join(j.dir, "*.toml"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list journal files in %s", j.dir)
+	}
+
+	sort.Strings(files)
+
+	var runs []Run
+	for _, f := range files {
+		var run Run
+		err = j.snapshot.ReadToml(&run, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read journal file %s", f)
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// Last returns the last run that hasn't been undone
+func (j Journal) Last() (*Run, error) {
+	runs, err := j.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		if !runs[i].Undone {
+			return &runs[i], nil
+		}
+	}
+
+	return nil, ErrNoRuns
+}
+
+// Get the run with the given id
+func (j Journal) Get(id string) (*Run, error) {
+	var run Run
+	err := j.snapshot.ReadToml(&run, j.file(id))
+	if err == fs.ErrNoSuchFile {
+		return nil, errors.Errorf("no run with id %s", id)
+	}
+
+	return &run, err
+}
+
+// Undo reverts the entries of the run in reverse order and marks it as
+// undone. Reverting continues past failures, returning all errors.
+func (j Journal) Undo(run *Run) error {
+	var result error
+	for i := len(run.Entries) - 1; i >= 0; i-- {
+		entry := run.Entries[i]
+		logger := logrus.WithField("entry", entry)
+		logger.Info("undoing entry")
+
+		err := j.revert(entry)
+		if err != nil {
+			logger.WithError(err).Error("failed to undo entry")
+			result = multierror.Append(result, errors.Wrapf(err, "failed to undo %s", entry))
+		}
+	}
+
+	run.Undone = true
+	err := j.snapshot.SaveToml(run, j.file(run.ID))
+	if err != nil {
+		result = multierror.Append(result, errors.Wrapf(err, "failed to mark run %s as undone", run.ID))
+	}
+
+	return result
+}
+
+func (j Journal) revert(entry Entry) error {
+	fs := j.snapshot.Fs
+
+	switch entry.Kind {
+	case Move:
+		err := j.snapshot.CreateNecessaryDirectories(entry.Path)
+		if err != nil {
+			return err
+		}
+
+		return fs.Rename(entry.Target, entry.Path)
+
+	case Symlink:
+		target, err := fs.Readlink(entry.Path)
+		if err != nil || target != entry.Target {
+			return errors.Errorf("%s is no longer a symlink to %s", entry.Path, entry.Target)
+		}
+
+		return fs.Remove(entry.Path)
+
+	case Remove:
+		err := j.snapshot.CreateNecessaryDirectories(entry.Path)
+		if err != nil {
+			return err
+		}
+
+		if entry.Target != "" {
+			return fs.Symlink(entry.Target, entry.Path)
+		}
+
+		if entry.Backup != "" {
+			return copyFile(j.snapshot, entry.Backup, entry.Path)
+		}
+
+		return fs.MkdirAll(entry.Path, os.ModePerm)
+
+	case Write:
+		if entry.Backup != "" {
+			return copyFile(j.snapshot, entry.Backup, entry.Path)
+		}
+
+		return fs.Remove(entry.Path)
+
+	case Mkdir:
+		return fs.Remove(entry.Path)
+
+	case Discard:
+		logrus.WithField("file", entry.Path).Warn("content was not kept, unable to restore it")
+		return nil
+	}
+
+	return errors.Errorf("unknown kind of entry: %s", entry.Kind)
+}
+
+func (j Journal) file(id string) string {
+	return filepath.Join(j.dir, id+".toml")
+}
+
+// copyFile copies the file, keeping its permissions so that a copy of a
+// private file is private as well.
+func copyFile(snapshot fs.Snapshot, from, to string) error {
+	src, err := snapshot.Fs.Open(from)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", from)
+	}
+	defer src.Close()
+
+	info, err := snapshot.Fs.Stat(from)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", from)
+	}
+
+	err = snapshot.CreateNecessaryDirectories(to)
+	if err != nil {
+		return err
+	}
+
+	dst, err := snapshot.Fs.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", to)
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return errors.Wrapf(err, "failed to copy %s to %s", from, to)
+}
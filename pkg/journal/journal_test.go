@@ -0,0 +1,202 @@
+package journal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	billy "gopkg.in/src-d/go-billy.v4"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/journal"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestJournal(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Journal Suite")
+}
+
+var _ = Describe("Journal", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var j journal.Journal
+	var run *journal.Run
+	var journaled billy.Filesystem
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		j = journal.New(snapshot, filepath.Join(config.PunktHome, "journal"))
+		run = j.Start()
+		journaled = journal.NewFilesystem(run, snapshot.Fs)
+	})
+
+	Context("Filesystem", func() {
+		It("should record moves and symlinks", func() {
+			Expect(snapshot.Save("content", "/file")).To(Succeed())
+
+			Expect(journaled.Rename("/file", "/moved")).To(Succeed())
+			Expect(journaled.Symlink("/moved", "/file")).To(Succeed())
+
+			Expect(run.Entries).To(Equal([]journal.Entry{
+				{Kind: journal.Move, Path: "/file", Target: "/moved"},
+				{Kind: journal.Symlink, Path: "/file", Target: "/moved"},
+			}))
+		})
+
+		It("should not record failed operations", func() {
+			Expect(journaled.Rename("/missing", "/moved")).NotTo(Succeed())
+			Expect(run.Entries).To(BeEmpty())
+		})
+
+		It("should record the directories created", func() {
+			Expect(journaled.MkdirAll("/a/b", os.ModePerm)).To(Succeed())
+
+			Expect(run.Entries).To(Equal([]journal.Entry{
+				{Kind: journal.Mkdir, Path: "/a"},
+				{Kind: journal.Mkdir, Path: "/a/b"},
+			}))
+		})
+
+		It("should back up files before writing to them", func() {
+			Expect(snapshot.Save("before", "/file")).To(Succeed())
+
+			f, err := journaled.Create("/file")
+			Expect(err).To(BeNil())
+			Expect(f.Close()).To(Succeed())
+
+			Expect(run.Entries).To(HaveLen(1))
+			content, err := snapshot.Read(run.Entries[0].Backup)
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("before"))
+		})
+
+		It("should keep the permissions of the files it backs up", func() {
+			Expect(snapshot.SavePrivate([]byte("private"), "/file")).To(Succeed())
+
+			Expect(journaled.Remove("/file")).To(Succeed())
+
+			fi, err := snapshot.Fs.Stat(run.Entries[0].Backup)
+			Expect(err).To(BeNil())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			fi, err = snapshot.Fs.Stat(filepath.Dir(run.Entries[0].Backup))
+			Expect(err).To(BeNil())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0700)))
+		})
+	})
+
+	Context("Undo", func() {
+		It("should revert the run", func() {
+			Expect(snapshot.Save("content", "/home/file")).To(Succeed())
+			Expect(snapshot.Save("config", "/home/config")).To(Succeed())
+			journaledSnapshot := fs.Snapshot{Fs: journaled, UserHome: snapshot.UserHome}
+
+			Expect(journaledSnapshot.CreateNecessaryDirectories("/home/.dotfiles/file")).To(Succeed())
+			Expect(journaled.Rename("/home/file", "/home/.dotfiles/file")).To(Succeed())
+			Expect(journaled.Symlink("/home/.dotfiles/file", "/home/file")).To(Succeed())
+			Expect(journaledSnapshot.Save("changed", "/home/config")).To(Succeed())
+			Expect(journaledSnapshot.Save("new", "/home/new")).To(Succeed())
+			Expect(j.Save(run)).To(Succeed())
+
+			last, err := j.Last()
+			Expect(err).To(BeNil())
+			Expect(j.Undo(last)).To(Succeed())
+
+			_, err = snapshot.Fs.Readlink("/home/file")
+			Expect(err).NotTo(BeNil())
+			content, err := snapshot.Read("/home/file")
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("content"))
+
+			content, err = snapshot.Read("/home/config")
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("config"))
+
+			_, err = snapshot.Fs.Stat("/home/new")
+			Expect(err).NotTo(BeNil())
+			_, err = snapshot.Fs.Stat("/home/.dotfiles")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should recreate removed symlinks", func() {
+			Expect(snapshot.Fs.Symlink("/target", "/link")).To(Succeed())
+			Expect(journaled.Remove("/link")).To(Succeed())
+
+			Expect(j.Undo(run)).To(Succeed())
+
+			target, err := snapshot.Fs.Readlink("/link")
+			Expect(err).To(BeNil())
+			Expect(target).To(Equal("/target"))
+		})
+
+		It("should mark the run as undone", func() {
+			Expect(journaled.Symlink("/target", "/link")).To(Succeed())
+			Expect(j.Save(run)).To(Succeed())
+
+			Expect(j.Undo(run)).To(Succeed())
+
+			_, err := j.Last()
+			Expect(err).To(Equal(journal.ErrNoRuns))
+
+			runs, err := j.Runs()
+			Expect(err).To(BeNil())
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Undone).To(BeTrue())
+		})
+
+		It("should continue reverting when some entry fails", func() {
+			Expect(journaled.Symlink("/target", "/link")).To(Succeed())
+			Expect(journaled.Symlink("/target", "/other")).To(Succeed())
+			Expect(snapshot.Fs.Remove("/other")).To(Succeed())
+
+			Expect(j.Undo(run)).NotTo(Succeed())
+
+			_, err := snapshot.Fs.Lstat("/link")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("Runs", func() {
+		It("should not save runs without entries", func() {
+			Expect(j.Save(run)).To(Succeed())
+
+			runs, err := j.Runs()
+			Expect(err).To(BeNil())
+			Expect(runs).To(BeEmpty())
+		})
+
+		It("should be possible to get a run by its id", func() {
+			run.Command = "ensure"
+			Expect(journaled.Symlink("/target", "/link")).To(Succeed())
+			Expect(j.Save(run)).To(Succeed())
+
+			actual, err := j.Get(run.ID)
+			Expect(err).To(BeNil())
+			Expect(actual.Command).To(Equal("ensure"))
+			Expect(actual.Entries).To(Equal(run.Entries))
+		})
+
+		It("should keep runs started in the same second apart", func() {
+			Expect(journaled.Symlink("/target", "/link")).To(Succeed())
+			Expect(j.Save(run)).To(Succeed())
+
+			second := j.Start()
+			Expect(second.ID).NotTo(Equal(run.ID))
+			Expect(journal.NewFilesystem(second, snapshot.Fs).Symlink("/target", "/other")).To(Succeed())
+			Expect(j.Save(second)).To(Succeed())
+
+			runs, err := j.Runs()
+			Expect(err).To(BeNil())
+			Expect(runs).To(HaveLen(2))
+		})
+
+		It("should fail to get a run that doesn't exist", func() {
+			_, err := j.Get("missing")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,101 @@
+package machine
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// Condition limits something in the configuration to the machines it
+// matches, the fields that are empty match every machine.
+type Condition struct {
+	// Host is a glob that the hostname must match
+	Host string `toml:"host,omitempty"`
+	OS   string `toml:"os,omitempty"`
+	Arch string `toml:"arch,omitempty"`
+	// Env is the name of an environment variable that must be set
+	Env string `toml:"env,omitempty"`
+}
+
+// ConditionFrom reads the condition from the host, os, arch and env keys of
+// the given map.
+func ConditionFrom(values map[string]string) Condition {
+	return Condition{
+		Host: values["host"],
+		OS:   values["os"],
+		Arch: values["arch"],
+		Env:  values["env"],
+	}
+}
+
+// AsMap returns the keys of the condition that are set
+func (c Condition) AsMap() map[string]string {
+	mapping := make(map[string]string)
+	for key, val := range map[string]string{"host": c.Host, "os": c.OS, "arch": c.Arch, "env": c.Env} {
+		if val != "" {
+			mapping[key] = val
+		}
+	}
+
+	return mapping
+}
+
+// Check returns the reason the current machine doesn't match the condition,
+// or an empty string if it does.
+func (c Condition) Check() (string, error) {
+	if c.Host != "" {
+		hostname, err := Hostname()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get the hostname")
+		}
+
+		match, err := filepath.Match(c.Host, hostname)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid host pattern %s", c.Host)
+		}
+
+		if !match {
+			return fmt.Sprintf("hostname %s does not match %s", hostname, c.Host), nil
+		}
+	}
+
+	if c.OS != "" && c.OS != OS {
+		return fmt.Sprintf("os is %s, not %s", OS, c.OS), nil
+	}
+
+	if c.Arch != "" && c.Arch != Arch {
+		return fmt.Sprintf("arch is %s, not %s", Arch, c.Arch), nil
+	}
+
+	if c.Env != "" {
+		if _, ok := LookupEnv(c.Env); !ok {
+			return fmt.Sprintf("environment variable %s is not set", c.Env), nil
+		}
+	}
+
+	return "", nil
+}
+
+// Applies checks if the condition matches the current machine, noting that
+// the item is skipped if it doesn't.
+func (c Condition) Applies(item string) (bool, error) {
+	reason, err := c.Check()
+	if err != nil {
+		return false, err
+	}
+
+	if reason != "" {
+		logrus.WithFields(logrus.Fields{
+			"item":   item,
+			"reason": reason,
+		}).Info("condition not met, skipping")
+		printer.Log.Note("skipping <fg 5>%s<reset>: %s", item, reason)
+		return false, nil
+	}
+
+	return true, nil
+}
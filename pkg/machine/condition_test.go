@@ -0,0 +1,78 @@
+package machine_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Machine: Condition", func() {
+	BeforeEach(func() {
+		testmock.Setup()
+		machine.Hostname = func() (string, error) { return "work-laptop", nil }
+		machine.LookupEnv = func(key string) (string, bool) { return "", key == "WORK" }
+	})
+
+	It("should match every machine if empty", func() {
+		reason, err := machine.Condition{}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(BeEmpty())
+	})
+
+	It("should match the hostname against a glob", func() {
+		reason, err := machine.Condition{Host: "work-*"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(BeEmpty())
+
+		reason, err = machine.Condition{Host: "server-*"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(Equal("hostname work-laptop does not match server-*"))
+	})
+
+	It("should fail if the host pattern is invalid", func() {
+		_, err := machine.Condition{Host: "["}.Check()
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should match the os and arch", func() {
+		reason, err := machine.Condition{OS: machine.OS, Arch: machine.Arch}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(BeEmpty())
+
+		reason, err = machine.Condition{OS: "plan9"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).NotTo(BeEmpty())
+
+		reason, err = machine.Condition{Arch: "mips"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).NotTo(BeEmpty())
+	})
+
+	It("should require the environment variable to be set", func() {
+		reason, err := machine.Condition{Env: "WORK"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(BeEmpty())
+
+		reason, err = machine.Condition{Env: "HOBBY"}.Check()
+		Expect(err).To(BeNil())
+		Expect(reason).To(Equal("environment variable HOBBY is not set"))
+	})
+
+	It("should only apply if the condition matches", func() {
+		ok, err := machine.Condition{Host: "work-*"}.Applies("item")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeTrue())
+
+		ok, err = machine.Condition{Host: "server-*"}.Applies("item")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should read and write the condition as a map", func() {
+		condition := machine.Condition{Host: "work-*", Env: "WORK"}
+		Expect(condition.AsMap()).To(Equal(map[string]string{"host": "work-*", "env": "WORK"}))
+		Expect(machine.ConditionFrom(condition.AsMap())).To(Equal(condition))
+	})
+})
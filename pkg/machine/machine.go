@@ -0,0 +1,65 @@
+package machine
+
+import (
+	"os"
+	"os/user"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// Hostname is used to get the name of the machine, it can be replaced
+	// to fake the machine in tests.
+	Hostname = os.Hostname
+	// CurrentUser is used to get the user running punkt
+	CurrentUser = user.Current
+	// OS is the operating system punkt is running on
+	OS = runtime.GOOS
+	// Arch is the architecture punkt is running on
+	Arch = runtime.GOARCH
+	// LookupEnv is used to check if an environment variable is set
+	LookupEnv = os.LookupEnv
+)
+
+// Facts describes the machine punkt runs on, it is what templates are
+// rendered with.
+type Facts struct {
+	Hostname  string
+	OS        string
+	Arch      string
+	User      string
+	Home      string
+	Variables map[string]string
+}
+
+// New gathers the facts about the current machine, the variables are the
+// custom ones given in the configuration.
+func New(variables map[string]string) (*Facts, error) {
+	hostname, err := Hostname()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the hostname")
+	}
+
+	usr, err := CurrentUser()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the current user")
+	}
+
+	if variables == nil {
+		variables = make(map[string]string)
+	}
+
+	facts := &Facts{
+		Hostname:  hostname,
+		OS:        OS,
+		Arch:      Arch,
+		User:      usr.Username,
+		Home:      usr.HomeDir,
+		Variables: variables,
+	}
+
+	logrus.WithField("facts", facts).Debug("gathered facts about the machine")
+	return facts, nil
+}
@@ -0,0 +1,57 @@
+package machine_test
+
+import (
+	"errors"
+	"os/user"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/machine"
+)
+
+func TestMachine(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Machine Suite")
+}
+
+var _ = Describe("Machine", func() {
+	BeforeEach(func() {
+		machine.Hostname = func() (string, error) { return "laptop", nil }
+		machine.CurrentUser = func() (*user.User, error) {
+			return &user.User{Username: "me", HomeDir: "/home/me"}, nil
+		}
+	})
+
+	It("should gather the facts about the machine", func() {
+		facts, err := machine.New(map[string]string{"email": "me@example.com"})
+		Expect(err).To(BeNil())
+		Expect(*facts).To(Equal(machine.Facts{
+			Hostname:  "laptop",
+			OS:        machine.OS,
+			Arch:      machine.Arch,
+			User:      "me",
+			Home:      "/home/me",
+			Variables: map[string]string{"email": "me@example.com"},
+		}))
+	})
+
+	It("should always have variables", func() {
+		facts, err := machine.New(nil)
+		Expect(err).To(BeNil())
+		Expect(facts.Variables).NotTo(BeNil())
+	})
+
+	It("should fail if the hostname can't be determined", func() {
+		machine.Hostname = func() (string, error) { return "", errors.New("fail") }
+		_, err := machine.New(nil)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should fail if the user can't be determined", func() {
+		machine.CurrentUser = func() (*user.User, error) { return nil, errors.New("fail") }
+		_, err := machine.New(nil)
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,91 @@
+package mgr
+
+import (
+	"path/filepath"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// ErrNoConfiguration is returned when a dotfiles repository doesn't contain
+// any punkt configuration
+var ErrNoConfiguration = errors.New("no punkt configuration found")
+
+// configLocations are where, relative to the dotfiles, the punkt
+// configuration is looked for
+var configLocations = []string{
+	".config/punkt/config.toml",
+	"punkt/config.toml",
+	"config.toml",
+}
+
+// Bootstrap sets up a machine from a dotfiles repository, cloning it from
+// the url into the dotfiles directory and then ensuring all managers using
+// the punkt configuration stored in it. If the configured punkt home hasn't
+// been set up, the directory the configuration was found in is used as punkt
+// home.
+func (rootMgr RootManager) Bootstrap(url string) error {
+	printer.Log.Start("bootstrap", "dotfiles: <fg 2>%s", url)
+
+	dotfiles := rootMgr.snapshot.UnexpandHome(rootMgr.config.Dotfiles)
+	err := rootMgr.Git().RepoManager.Clone(url, rootMgr.config.Dotfiles)
+	if err != nil {
+		printer.Log.Error("failed to clone the dotfiles: <fg 1>%s", err)
+		return errors.Wrapf(err, "unable to clone %s", url)
+	}
+
+	if rootMgr.Plan != nil {
+		printer.Log.Note("nothing more can be planned before <fg 5>%s<reset> is cloned", dotfiles)
+		return nil
+	}
+
+	printer.Log.Success("cloned into: <fg 2>%s", dotfiles)
+
+	file, err := rootMgr.locateConfig()
+	if err != nil {
+		printer.Log.Error("found no configuration in <fg 5>%s", dotfiles)
+		return err
+	}
+
+	config, err := conf.NewConfig(rootMgr.snapshot, file)
+	if err != nil {
+		printer.Log.Error("failed to read configuration: <fg 1>%s", err)
+		return err
+	}
+
+	if _, err := rootMgr.snapshot.Fs.Stat(filepath.Join(config.PunktHome, "config.toml")); err != nil {
+		printer.Log.Note("punkt home isn't set up, using <fg 5>%s", rootMgr.snapshot.UnexpandHome(filepath.Dir(file)))
+		config.PunktHome = filepath.Dir(file)
+	}
+
+	bootstrapped := NewRootManager(*config, rootMgr.snapshot)
+	bootstrapped.Jobs = rootMgr.Jobs
+	mgrs := bootstrapped.All()
+	err = bootstrapped.Ensure(mgrs)
+
+	if merr, ok := err.(*multierror.Error); ok {
+		printer.Log.Error("bootstrapped <fg 5>%s<reset>, but ensure failed with <fg 1>%d<reset> errors", dotfiles, len(merr.Errors))
+		return err
+	} else if err != nil {
+		printer.Log.Error("bootstrapped <fg 5>%s<reset>, but ensure failed: <fg 1>%s", dotfiles, err)
+		return err
+	}
+
+	printer.Log.Done("bootstrap", "bootstrapped <fg 2>%s<reset> with %d managers", dotfiles, len(mgrs))
+	return nil
+}
+
+// locateConfig finds the punkt configuration in the dotfiles
+func (rootMgr RootManager) locateConfig() (string, error) {
+	for _, location := range configLocations {
+		file := filepath.Join(rootMgr.config.Dotfiles, location)
+		if _, err := rootMgr.snapshot.Fs.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+
+	return "", errors.Wrapf(ErrNoConfiguration, "looked for %v in %s", configLocations, rootMgr.config.Dotfiles)
+}
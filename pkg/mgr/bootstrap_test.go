@@ -0,0 +1,101 @@
+package mgr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	goGit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/testmock"
+)
+
+// newOrigin creates a repository on disk with a single commit of the given
+// files
+func newOrigin(files map[string]string) string {
+	dir, err := ioutil.TempDir("", "bootstrap-origin")
+	Expect(err).To(BeNil())
+
+	repo, err := goGit.PlainInit(dir, false)
+	Expect(err).To(BeNil())
+	w, err := repo.Worktree()
+	Expect(err).To(BeNil())
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		_, err = w.Add(name)
+		Expect(err).To(BeNil())
+	}
+
+	_, err = w.Commit("dotfiles", &goGit.CommitOptions{
+		Author: &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()},
+	})
+	Expect(err).To(BeNil())
+
+	return dir
+}
+
+var _ = Describe("Manager: Bootstrap", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var root *mgr.RootManager
+	var origin string
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		root = mgr.NewRootManager(config, snapshot)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(origin)).To(Succeed())
+	})
+
+	It("should clone the dotfiles and ensure them using their configuration", func() {
+		origin = newOrigin(map[string]string{
+			".vimrc":                     "set nocompatible",
+			".config/punkt/config.toml":  `dotfiles = "/home/.dotfiles"` + "\n" + `punktHome = "/home/.config/punkt"`,
+			".config/punkt/symlink.toml": `"~/.vimrc" = "~/.dotfiles/.vimrc"`,
+		})
+
+		Expect(root.Bootstrap(origin)).To(Succeed())
+
+		content, err := snapshot.Read(filepath.Join(config.Dotfiles, ".vimrc"))
+		Expect(err).To(BeNil())
+		Expect(content).To(Equal("set nocompatible"))
+
+		target, err := snapshot.Fs.Readlink(filepath.Join(snapshot.UserHome, ".vimrc"))
+		Expect(err).To(BeNil())
+		Expect(target).To(Equal(filepath.Join(config.Dotfiles, ".vimrc")))
+	})
+
+	It("should fail if the repository has no punkt configuration", func() {
+		origin = newOrigin(map[string]string{".vimrc": "set nocompatible"})
+
+		err := root.Bootstrap(origin)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring(mgr.ErrNoConfiguration.Error()))
+	})
+
+	It("should fail if the repository can't be cloned", func() {
+		origin = ""
+		Expect(root.Bootstrap("/does/not/exist")).NotTo(Succeed())
+	})
+
+	It("should only plan the clone when doing a dry run", func() {
+		origin = ""
+		root.Plan = plan.New()
+
+		Expect(root.Bootstrap("/origin")).To(Succeed())
+		Expect(root.Plan.Operations).To(ConsistOf(plan.Operation{Kind: "clone", Args: []string{"/origin", "->", config.Dotfiles}}))
+	})
+})
@@ -0,0 +1,161 @@
+package brew
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/run"
+)
+
+// Name is what the manager is configured as in managers.toml
+const Name = "brew"
+
+// Manager keeps what is installed with Homebrew in a Brewfile in the
+// dotfiles, using brew bundle.
+type Manager struct {
+	// Prefixed is set when managers run at the same time, the output of
+	// ensure and update is then prefixed with the manager's name.
+	Prefixed bool
+	brewfile string
+	settings map[string]string
+	snapshot fs.Snapshot
+}
+
+// NewManager creates a manager storing the Brewfile in the dotfiles, unless
+// another location is configured with brewfile in managers.toml.
+func NewManager(c conf.Config, snapshot fs.Snapshot) *Manager {
+	settings := c.Managers[Name]
+	brewfile := filepath.Join(c.Dotfiles, "Brewfile")
+	if file, ok := settings["brewfile"]; ok {
+		brewfile = snapshot.ExpandHome(file)
+	}
+
+	logrus.WithField("brewfile", brewfile).Info("Constructing brew manager")
+
+	return &Manager{
+		brewfile: brewfile,
+		settings: settings,
+		snapshot: snapshot,
+	}
+}
+
+// Name ...
+func (mgr Manager) Name() string {
+	return Name
+}
+
+// Condition returns the host, os, arch and env condition configured for the
+// manager in managers.toml.
+func (mgr Manager) Condition() machine.Condition {
+	return machine.ConditionFrom(mgr.settings)
+}
+
+// File returns the Brewfile
+func (mgr Manager) File() string {
+	return mgr.brewfile
+}
+
+// Dump writes what is currently installed to the Brewfile. Nothing is
+// returned as the Brewfile is the manager's configuration.
+func (mgr Manager) Dump() (string, error) {
+	out, err := mgr.installed()
+	if err != nil {
+		return "", err
+	}
+
+	err = mgr.snapshot.Save(out, mgr.brewfile)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to save %s", mgr.brewfile)
+	}
+
+	return "", nil
+}
+
+// Ensure installs everything in the Brewfile
+func (mgr Manager) Ensure() error {
+	return run.ForUser(run.Commander("brew", "bundle", "install", "--file="+mgr.brewfile), run.Prefix(Name, mgr.Prefixed))
+}
+
+// Update upgrades everything installed
+func (mgr Manager) Update() error {
+	return run.ForUser(run.Commander("brew", "upgrade"), run.Prefix(Name, mgr.Prefixed))
+}
+
+// Status reports what is installed but missing from the Brewfile, and what
+// is in the Brewfile but isn't installed.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	stored, err := mgr.snapshot.Read(mgr.brewfile)
+	if err == fs.ErrNoSuchFile {
+		return []drift.Drift{drift.New(mgr.snapshot.UnexpandHome(mgr.brewfile), "no Brewfile")}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	out, err := mgr.installed()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := entries(stored)
+	installed := entries(out)
+
+	var drifts []drift.Drift
+	for _, e := range installed.order {
+		if !configured.has[e] {
+			drifts = append(drifts, drift.New(e, "installed but missing from the Brewfile"))
+		}
+	}
+
+	for _, e := range configured.order {
+		if !installed.has[e] {
+			drifts = append(drifts, drift.New(e, "in the Brewfile but not installed"))
+		}
+	}
+
+	return drifts, nil
+}
+
+// installed returns a Brewfile describing what is currently installed
+func (mgr Manager) installed() (string, error) {
+	out, err := run.ReadOnly("brew", "bundle", "dump", "--file=-").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to dump what is installed with brew")
+	}
+
+	return string(out), nil
+}
+
+type brewfile struct {
+	order []string
+	has   map[string]bool
+}
+
+// entries parses the Brewfile into its entries, such as brew "git" or
+// cask "firefox", leaving out the options given to them.
+func entries(content string) brewfile {
+	b := brewfile{has: make(map[string]bool)}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if i := strings.Index(line, ","); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		if !b.has[line] {
+			b.order = append(b.order, line)
+			b.has[line] = true
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,189 @@
+package brew_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/brew"
+	"github.com/mbark/punkt/pkg/run"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestBrew(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Brew Suite")
+}
+
+const installed = `tap "homebrew/core"
+brew "git"
+brew "postgresql", restart_service: true
+cask "firefox"
+`
+
+var _ = Describe("Brew Manager", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr *brew.Manager
+	var brewfile string
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		run.Commander = testmock.FakeCommand("TestBrewHelperProcess")
+		testmock.NoOutput()
+
+		config.Managers = map[string]map[string]string{
+			brew.Name: {},
+		}
+
+		brewfile = filepath.Join(config.Dotfiles, "Brewfile")
+		mgr = brew.NewManager(config, snapshot)
+	})
+
+	It("should have the name brew", func() {
+		Expect(mgr.Name()).To(Equal("brew"))
+	})
+
+	It("should read its condition from the manager config", func() {
+		config.Managers[brew.Name]["os"] = "darwin"
+		mgr = brew.NewManager(config, snapshot)
+
+		Expect(mgr.Condition()).To(Equal(machine.Condition{OS: "darwin"}))
+	})
+
+	var _ = Context("Dump", func() {
+		It("should write what is installed to the Brewfile", func() {
+			out, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(out).To(BeEmpty())
+
+			Expect(snapshot.Read(brewfile)).To(Equal(installed))
+		})
+
+		It("should use the configured Brewfile", func() {
+			config.Managers[brew.Name]["brewfile"] = "~/Brewfile"
+			mgr = brew.NewManager(config, snapshot)
+
+			_, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(snapshot.Read(filepath.Join(snapshot.UserHome, "Brewfile"))).To(Equal(installed))
+		})
+
+		It("should fail if brew fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestBrewHelperProcess", "FAILING=true")
+
+			_, err := mgr.Dump()
+			Expect(err).NotTo(BeNil())
+			_, err = snapshot.Fs.Stat(brewfile)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	var _ = Context("Ensure", func() {
+		It("should install the Brewfile with brew bundle", func() {
+			out := new(bytes.Buffer)
+			run.Out = out
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("bundle install --file=%s\n", brewfile)))
+		})
+
+		It("should prefix the output with its name when prefixed", func() {
+			out := new(bytes.Buffer)
+			run.Out = out
+			mgr.Prefixed = true
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("brew | bundle install --file=%s\n", brewfile)))
+		})
+
+		It("should fail if brew fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestBrewHelperProcess", "FAILING=true")
+			Expect(mgr.Ensure()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Update", func() {
+		It("should upgrade with brew", func() {
+			out := new(bytes.Buffer)
+			run.Out = out
+
+			Expect(mgr.Update()).To(Succeed())
+			Expect(out.String()).To(Equal("upgrade\n"))
+		})
+
+		It("should fail if brew fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestBrewHelperProcess", "FAILING=true")
+			Expect(mgr.Update()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Status", func() {
+		It("should report drift if there is no Brewfile", func() {
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(drift.New("~/.dotfiles/Brewfile", "no Brewfile")))
+		})
+
+		It("should report nothing if the Brewfile has what is installed", func() {
+			Expect(snapshot.Save(strings.Replace(installed, ", restart_service: true", "", 1), brewfile)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should report what is missing from or extra to the Brewfile", func() {
+			Expect(snapshot.Save(`# my packages
+tap "homebrew/core"
+brew "git"
+brew "postgresql"
+brew "vim"
+`, brewfile)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(Equal([]drift.Drift{
+				drift.New(`cask "firefox"`, "installed but missing from the Brewfile"),
+				drift.New(`brew "vim"`, "in the Brewfile but not installed"),
+			}))
+		})
+
+		It("should fail if brew fails", func() {
+			Expect(snapshot.Save(installed, brewfile)).To(Succeed())
+			run.Commander = testmock.FakeWithEnvCommand("TestBrewHelperProcess", "FAILING=true")
+
+			_, err := mgr.Status()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
+
+func TestBrewHelperProcess(t *testing.T) {
+	cmd, args, err := testmock.VerifyHelperProcess()
+	if err != nil {
+		return
+	}
+
+	if cmd != "brew" {
+		fmt.Fprintf(os.Stderr, "should always use brew, cmd: %v, args: %v\n", cmd, args)
+		os.Exit(1)
+	}
+
+	if strings.Join(args, " ") == "bundle dump --file=-" {
+		fmt.Print(installed)
+	} else {
+		fmt.Println(strings.Join(args, " "))
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,271 @@
+package distro
+
+import (
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
+)
+
+// Tool describes how a distribution's package manager is run
+type Tool struct {
+	// List prints the explicitly installed packages, one per line
+	List []string
+	// Install is given the packages to install
+	Install []string
+	// Upgrade are run in order to upgrade the system
+	Upgrade [][]string
+}
+
+// Tools are the package managers that are built in, by the name they are
+// configured as in managers.toml.
+var Tools = map[string]Tool{
+	"apt": {
+		List:    []string{"apt-mark", "showmanual"},
+		Install: []string{"apt-get", "install", "-y"},
+		Upgrade: [][]string{{"apt-get", "update"}, {"apt-get", "upgrade", "-y"}},
+	},
+	"dnf": {
+		List:    []string{"dnf", "repoquery", "--userinstalled", "--queryformat", "%{name}"},
+		Install: []string{"dnf", "install", "-y"},
+		Upgrade: [][]string{{"dnf", "upgrade", "-y"}},
+	},
+	"pacman": {
+		List:    []string{"pacman", "-Qqe"},
+		Install: []string{"pacman", "-S", "--needed", "--noconfirm"},
+		Upgrade: [][]string{{"pacman", "-Syu", "--noconfirm"}},
+	},
+}
+
+// Manager keeps the packages explicitly installed with the distribution's
+// package manager in a file in the dotfiles, one package per line.
+type Manager struct {
+	// Prefixed is set when managers run at the same time, the output of
+	// ensure and update is then prefixed with the manager's name.
+	Prefixed bool
+	name     string
+	tool     Tool
+	file     string
+	settings map[string]string
+	snapshot fs.Snapshot
+}
+
+// NewManager creates a manager for one of the Tools, storing the packages
+// in <name>-packages.txt in the dotfiles unless another file is configured
+// with file in managers.toml.
+func NewManager(c conf.Config, snapshot fs.Snapshot, name string) *Manager {
+	settings := c.Managers[name]
+	file := filepath.Join(c.Dotfiles, name+"-packages.txt")
+	if f, ok := settings["file"]; ok {
+		file = snapshot.ExpandHome(f)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name": name,
+		"file": file,
+	}).Info("Constructing distribution package manager")
+
+	return &Manager{
+		name:     name,
+		tool:     Tools[name],
+		file:     file,
+		settings: settings,
+		snapshot: snapshot,
+	}
+}
+
+// Name ...
+func (mgr Manager) Name() string {
+	return mgr.name
+}
+
+// Condition returns the condition configured for the manager in
+// managers.toml, the manager is only run on linux unless another os is
+// configured.
+func (mgr Manager) Condition() machine.Condition {
+	condition := machine.ConditionFrom(mgr.settings)
+	if condition.OS == "" {
+		condition.OS = "linux"
+	}
+
+	return condition
+}
+
+// File returns the package file
+func (mgr Manager) File() string {
+	return mgr.file
+}
+
+// Dump writes the explicitly installed packages to the package file. Nothing
+// is returned as the package file is the manager's configuration.
+func (mgr Manager) Dump() (string, error) {
+	installed, err := mgr.installed()
+	if err != nil {
+		return "", err
+	}
+
+	err = mgr.snapshot.Save(strings.Join(installed, "\n")+"\n", mgr.file)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to save %s", mgr.file)
+	}
+
+	return "", nil
+}
+
+// Ensure installs the packages in the package file that aren't installed
+func (mgr Manager) Ensure() error {
+	missing, _, err := mgr.diff()
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		printer.Log.Note("all packages for <fg 5>%s<reset> are installed", mgr.name)
+		return nil
+	}
+
+	cmd, err := mgr.escalated(append(append([]string{}, mgr.tool.Install...), missing...))
+	if err != nil {
+		return err
+	}
+
+	return run.ForUser(cmd, run.Prefix(mgr.name, mgr.Prefixed))
+}
+
+// Update upgrades the system
+func (mgr Manager) Update() error {
+	for _, upgrade := range mgr.tool.Upgrade {
+		cmd, err := mgr.escalated(upgrade)
+		if err != nil {
+			return err
+		}
+
+		err = run.ForUser(cmd, run.Prefix(mgr.name, mgr.Prefixed))
+		if err != nil {
+			return errors.Wrapf(err, "%s failed", strings.Join(upgrade, " "))
+		}
+	}
+
+	return nil
+}
+
+// Status reports the packages in the package file that aren't installed,
+// and those explicitly installed that are missing from the package file.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	if _, err := mgr.snapshot.Fs.Stat(mgr.file); err != nil {
+		return []drift.Drift{drift.New(mgr.snapshot.UnexpandHome(mgr.file), "no package file")}, nil
+	}
+
+	missing, extra, err := mgr.diff()
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []drift.Drift
+	for _, p := range extra {
+		drifts = append(drifts, drift.New(p, "installed but missing from %s", mgr.snapshot.UnexpandHome(mgr.file)))
+	}
+
+	for _, p := range missing {
+		drifts = append(drifts, drift.New(p, "in %s but not installed", mgr.snapshot.UnexpandHome(mgr.file)))
+	}
+
+	return drifts, nil
+}
+
+// diff returns the packages in the package file that aren't installed and
+// those installed that aren't in the package file.
+func (mgr Manager) diff() (missing, extra []string, err error) {
+	content, err := mgr.snapshot.Read(mgr.file)
+	if err != nil && err != fs.ErrNoSuchFile {
+		return nil, nil, errors.Wrapf(err, "unable to read %s", mgr.file)
+	}
+
+	installed, err := mgr.installed()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wanted := packages(content)
+	has := make(map[string]bool)
+	for _, p := range installed {
+		has[p] = true
+	}
+
+	for _, p := range wanted {
+		if !has[p] {
+			missing = append(missing, p)
+		}
+		delete(has, p)
+	}
+
+	for _, p := range installed {
+		if has[p] {
+			extra = append(extra, p)
+		}
+	}
+
+	return missing, extra, nil
+}
+
+// installed returns the explicitly installed packages, sorted
+func (mgr Manager) installed() ([]string, error) {
+	out, err := run.ReadOnly(mgr.tool.List[0], mgr.tool.List[1:]...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the packages installed with %s", mgr.name)
+	}
+
+	installed := packages(string(out))
+	sort.Strings(installed)
+	return installed, nil
+}
+
+// escalated creates the command to run as root. If punkt isn't run as root
+// the command is run with sudo, or the command configured with sudo in
+// managers.toml, which is set to an empty string to never escalate.
+func (mgr Manager) escalated(args []string) (*exec.Cmd, error) {
+	usr, err := machine.CurrentUser()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the current user")
+	}
+
+	sudo, ok := mgr.settings["sudo"]
+	if !ok {
+		sudo = "sudo"
+	}
+
+	if usr.Uid == "0" || sudo == "" {
+		return run.Commander(args[0], args[1:]...), nil
+	}
+
+	printer.Log.Note("running <fg 5>%s<reset> with %s", strings.Join(args, " "), sudo)
+	return run.Commander(sudo, args...), nil
+}
+
+// packages parses the package names, one per line, ignoring empty lines and
+// comments.
+func packages(content string) []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+
+		seen[line] = true
+		found = append(found, line)
+	}
+
+	return found
+}
@@ -0,0 +1,233 @@
+package distro_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/distro"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/pkg/run"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestDistro(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Distro Suite")
+}
+
+const installed = "vim\ncurl\ngit\n"
+
+var _ = Describe("Distro Manager", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr *distro.Manager
+	var file string
+	var out *bytes.Buffer
+	var uid string
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		run.Commander = testmock.FakeCommand("TestDistroHelperProcess")
+		out = new(bytes.Buffer)
+		run.Out = out
+
+		uid = "1000"
+		machine.CurrentUser = func() (*user.User, error) {
+			return &user.User{Uid: uid, Username: "user", HomeDir: snapshot.UserHome}, nil
+		}
+
+		config.Managers = map[string]map[string]string{
+			"apt": {},
+		}
+
+		file = filepath.Join(config.Dotfiles, "apt-packages.txt")
+		mgr = distro.NewManager(config, snapshot, "apt")
+	})
+
+	It("should have the name it is configured as", func() {
+		Expect(mgr.Name()).To(Equal("apt"))
+	})
+
+	It("should only run on linux unless configured otherwise", func() {
+		Expect(mgr.Condition()).To(Equal(machine.Condition{OS: "linux"}))
+
+		config.Managers["apt"]["os"] = "darwin"
+		config.Managers["apt"]["host"] = "work-*"
+		mgr = distro.NewManager(config, snapshot, "apt")
+		Expect(mgr.Condition()).To(Equal(machine.Condition{OS: "darwin", Host: "work-*"}))
+	})
+
+	var _ = Context("Dump", func() {
+		It("should write the installed packages to the package file", func() {
+			dump, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(dump).To(BeEmpty())
+
+			Expect(snapshot.Read(file)).To(Equal("curl\ngit\nvim\n"))
+		})
+
+		It("should use the configured package file", func() {
+			config.Managers["apt"]["file"] = "~/packages"
+			mgr = distro.NewManager(config, snapshot, "apt")
+
+			_, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(snapshot.Read(filepath.Join(snapshot.UserHome, "packages"))).To(Equal("curl\ngit\nvim\n"))
+		})
+
+		It("should list the packages with the distribution's tool", func() {
+			for _, name := range []string{"dnf", "pacman"} {
+				mgr = distro.NewManager(config, snapshot, name)
+				_, err := mgr.Dump()
+				Expect(err).To(BeNil())
+				Expect(snapshot.Read(filepath.Join(config.Dotfiles, name+"-packages.txt"))).To(Equal("curl\ngit\nvim\n"))
+			}
+		})
+
+		It("should fail if listing the packages fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestDistroHelperProcess", "FAILING=true")
+
+			_, err := mgr.Dump()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	var _ = Context("Ensure", func() {
+		It("should install the missing packages with sudo", func() {
+			Expect(snapshot.Save("# tools\ngit\nhtop\njq\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal("sudo apt-get install -y htop jq\n"))
+		})
+
+		It("should still list the installed packages when doing a dry run", func() {
+			Expect(snapshot.Save("git\nhtop\n", file)).To(Succeed())
+			readOnly := run.ReadOnly
+			defer func() { run.ReadOnly = readOnly }()
+
+			p := plan.New()
+			run.ReadOnly = run.Commander
+			run.Commander = p.Commander
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(p.Operations).To(Equal([]plan.Operation{
+				{Kind: "run", Args: []string{"sudo", "apt-get", "install", "-y", "htop"}},
+			}))
+		})
+
+		It("should not use sudo when run as root", func() {
+			uid = "0"
+			Expect(snapshot.Save("htop\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal("apt-get install -y htop\n"))
+		})
+
+		It("should use the configured command to escalate with", func() {
+			config.Managers["apt"]["sudo"] = "doas"
+			mgr = distro.NewManager(config, snapshot, "apt")
+			Expect(snapshot.Save("htop\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal("doas apt-get install -y htop\n"))
+		})
+
+		It("should not escalate if configured not to", func() {
+			config.Managers["apt"]["sudo"] = ""
+			mgr = distro.NewManager(config, snapshot, "apt")
+			Expect(snapshot.Save("htop\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal("apt-get install -y htop\n"))
+		})
+
+		It("should do nothing if all packages are installed", func() {
+			Expect(snapshot.Save("git\nvim\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(BeEmpty())
+		})
+
+		It("should prefix the output with its name when prefixed", func() {
+			mgr.Prefixed = true
+			Expect(snapshot.Save("htop\n", file)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal("apt | sudo apt-get install -y htop\n"))
+		})
+
+		It("should fail if the packages can't be listed", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestDistroHelperProcess", "FAILING=true")
+			Expect(mgr.Ensure()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Update", func() {
+		It("should upgrade the system", func() {
+			Expect(mgr.Update()).To(Succeed())
+			Expect(out.String()).To(Equal("sudo apt-get update\nsudo apt-get upgrade -y\n"))
+		})
+
+		It("should fail if the upgrade fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestDistroHelperProcess", "FAILING=true")
+			Expect(mgr.Update()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Status", func() {
+		It("should report drift if there is no package file", func() {
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(drift.New("~/.dotfiles/apt-packages.txt", "no package file")))
+		})
+
+		It("should report nothing if the package file has what is installed", func() {
+			Expect(snapshot.Save(installed, file)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should report what is missing from or extra to the package file", func() {
+			Expect(snapshot.Save("git\nhtop\nvim\n", file)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(Equal([]drift.Drift{
+				drift.New("curl", "installed but missing from ~/.dotfiles/apt-packages.txt"),
+				drift.New("htop", "in ~/.dotfiles/apt-packages.txt but not installed"),
+			}))
+		})
+	})
+})
+
+func TestDistroHelperProcess(t *testing.T) {
+	cmd, args, err := testmock.VerifyHelperProcess()
+	if err != nil {
+		return
+	}
+
+	command := strings.Join(append([]string{cmd}, args...), " ")
+	for _, tool := range distro.Tools {
+		if command == strings.Join(tool.List, " ") {
+			fmt.Print(installed)
+			os.Exit(0)
+		}
+	}
+
+	fmt.Println(command)
+	os.Exit(0)
+}
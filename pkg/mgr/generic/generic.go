@@ -5,15 +5,23 @@ import (
 	"strings"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
 	"github.com/mbark/punkt/pkg/run"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 // Manager ...
 type Manager struct {
+	// Prefixed is set when managers run at the same time, the output of
+	// ensure and update is then prefixed with the manager's name.
+	Prefixed   bool
 	name       string
 	config     conf.Config
+	snapshot   fs.Snapshot
 	commands   map[string]string
 	configFile string
 }
@@ -24,7 +32,7 @@ type Config struct {
 }
 
 // NewManager ...
-func NewManager(c conf.Config, configFile, name string) *Manager {
+func NewManager(c conf.Config, snapshot fs.Snapshot, configFile, name string) *Manager {
 	logrus.WithFields(logrus.Fields{
 		"name":     name,
 		"commands": c.Managers[name],
@@ -33,6 +41,7 @@ func NewManager(c conf.Config, configFile, name string) *Manager {
 	return &Manager{
 		name:       name,
 		config:     c,
+		snapshot:   snapshot,
 		commands:   c.Managers[name],
 		configFile: configFile,
 	}
@@ -65,6 +74,12 @@ func (mgr Manager) Name() string {
 	return mgr.name
 }
 
+// Condition returns the host, os, arch and env condition configured for the
+// manager, limiting which machines it is run on.
+func (mgr Manager) Condition() machine.Condition {
+	return machine.ConditionFrom(mgr.commands)
+}
+
 // Dump ...
 func (mgr Manager) Dump() (string, error) {
 	cmd := mgr.resolveCommand("dump")
@@ -76,15 +91,34 @@ func (mgr Manager) Dump() (string, error) {
 // Update ...
 func (mgr Manager) Update() error {
 	cmd := mgr.resolveCommand("ensure", mgr.configFile)
-	run.PrintToUser(cmd)
-	err := cmd.Run()
-	return err
+	return run.ForUser(cmd, run.Prefix(mgr.name, mgr.Prefixed))
 }
 
 // Ensure ...
 func (mgr Manager) Ensure() error {
 	cmd := mgr.resolveCommand("ensure", mgr.configFile)
-	run.PrintToUser(cmd)
-	err := cmd.Run()
-	return err
+	return run.ForUser(cmd, run.Prefix(mgr.name, mgr.Prefixed))
+}
+
+// Status compares the output of dump with the stored configuration file,
+// reporting drift if they differ.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	out, err := mgr.Dump()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dump %s", mgr.name)
+	}
+
+	item := mgr.snapshot.UnexpandHome(mgr.configFile)
+	stored, err := mgr.snapshot.Read(mgr.configFile)
+	if err == fs.ErrNoSuchFile {
+		return []drift.Drift{drift.New(item, "no stored configuration")}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if stored != out {
+		return []drift.Drift{drift.New(item, "differs from the output of dump")}, nil
+	}
+
+	return nil, nil
 }
@@ -1,6 +1,7 @@
 package generic_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr/generic"
 	"github.com/mbark/punkt/pkg/run"
 	"github.com/mbark/punkt/testmock"
@@ -23,12 +26,13 @@ func TestMgr(t *testing.T) {
 const name = "generic"
 
 var _ = Describe("Generic Manager", func() {
+	var snapshot fs.Snapshot
 	var config conf.Config
 	var mgr *generic.Manager
 	var configFile string
 
 	BeforeEach(func() {
-		_, config = testmock.Setup()
+		snapshot, config = testmock.Setup()
 		run.Commander = testmock.FakeCommand("TestGenericHelperProcess")
 
 		managers := make(map[string]map[string]string)
@@ -37,13 +41,21 @@ var _ = Describe("Generic Manager", func() {
 		config.Managers = managers
 
 		configFile = filepath.Join(config.PunktHome, name+".toml")
-		mgr = generic.NewManager(config, configFile, name)
+		mgr = generic.NewManager(config, snapshot, configFile, name)
 	})
 
 	It("should have the name generic", func() {
 		Expect(mgr.Name()).To(Equal("generic"))
 	})
 
+	It("should read its condition from the manager config", func() {
+		config.Managers[name]["host"] = "work-*"
+		config.Managers[name]["os"] = "darwin"
+		mgr = generic.NewManager(config, snapshot, configFile, name)
+
+		Expect(mgr.Condition()).To(Equal(machine.Condition{Host: "work-*", OS: "darwin"}))
+	})
+
 	var _ = Context("Dump", func() {
 		It("should default to using generic", func() {
 			out, err := mgr.Dump()
@@ -53,7 +65,7 @@ var _ = Describe("Generic Manager", func() {
 
 		It("should fail if the command fails", func() {
 			run.Commander = testmock.FakeWithEnvCommand("TestGenericHelperProcess", "FAILING=true")
-			mgr = generic.NewManager(config, configFile, name)
+			mgr = generic.NewManager(config, snapshot, configFile, name)
 			_, err := mgr.Dump()
 
 			Expect(err).NotTo(BeNil())
@@ -61,7 +73,7 @@ var _ = Describe("Generic Manager", func() {
 
 		It("should prefer using 'dump' over 'command'", func() {
 			config.Managers[name]["dump"] = "foo"
-			mgr = generic.NewManager(config, configFile, name)
+			mgr = generic.NewManager(config, snapshot, configFile, name)
 
 			out, err := mgr.Dump()
 			Expect(err).To(BeNil())
@@ -77,13 +89,45 @@ var _ = Describe("Generic Manager", func() {
 
 		It("should fail if the command fails", func() {
 			run.Commander = testmock.FakeWithEnvCommand("TestGenericHelperProcess", "FAILING=true")
-			mgr = generic.NewManager(config, configFile, name)
+			mgr = generic.NewManager(config, snapshot, configFile, name)
 			err := mgr.Update()
 
 			Expect(err).NotTo(BeNil())
 		})
 	})
 
+	var _ = Context("Status", func() {
+		It("should report drift if there is no stored configuration", func() {
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(HaveLen(1))
+		})
+
+		It("should report drift if the stored configuration differs", func() {
+			Expect(snapshot.Save("something else", configFile)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(HaveLen(1))
+		})
+
+		It("should report nothing if the stored configuration is the same", func() {
+			Expect(snapshot.Save(name+" dump", configFile)).To(Succeed())
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should fail if dump fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestGenericHelperProcess", "FAILING=true")
+			mgr = generic.NewManager(config, snapshot, configFile, name)
+
+			_, err := mgr.Status()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
 	var _ = Context("Ensure", func() {
 		It("should succeed if the command does", func() {
 			err := mgr.Ensure()
@@ -92,11 +136,20 @@ var _ = Describe("Generic Manager", func() {
 
 		It("should fail if the command fails", func() {
 			run.Commander = testmock.FakeWithEnvCommand("TestGenericHelperProcess", "FAILING=true")
-			mgr = generic.NewManager(config, configFile, name)
+			mgr = generic.NewManager(config, snapshot, configFile, name)
 			err := mgr.Ensure()
 
 			Expect(err).NotTo(BeNil())
 		})
+
+		It("should prefix the output with its name when prefixed", func() {
+			out := new(bytes.Buffer)
+			run.Out = out
+			mgr.Prefixed = true
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("%s | %s ensure %s\n", name, name, configFile)))
+		})
 	})
 })
 
@@ -2,11 +2,16 @@ package git
 
 import (
 	"bytes"
+	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/printer"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	gitconf "gopkg.in/src-d/go-git.v4/config"
@@ -17,11 +22,58 @@ import (
 // ErrRepositoryNotFoundInConfig ...
 var ErrRepositoryNotFoundInConfig = errors.New("repository not found in config")
 
+// ErrNotPinned is returned when bumping the pin of a repository that isn't
+// pinned
+var ErrNotPinned = errors.New("repository isn't pinned")
+
 // Repo describes a git repository
 type Repo struct {
-	Name   string
-	Path   string
-	Config *gitconf.Config
+	Name string `toml:"name,omitempty"`
+	// Path is stored relative to the home directory, starting with ~, when
+	// the repository is in it
+	Path string `toml:"path"`
+	// URL is where the repository is cloned from, its origin remote, and
+	// Remotes are the URLs of its other remotes by their name
+	URL     string            `toml:"url,omitempty"`
+	Remotes map[string]string `toml:"remotes,omitempty"`
+	// Git are the repository's own git config keys, such as user.email, see
+	// ConfigKeys for those kept when it is added
+	Git map[string]string `toml:"git,omitempty"`
+	// Branch, Tag and Commit pin the repository to what is checked out when
+	// it is cloned and updated. If several are set the commit is used before
+	// the tag and the tag before the branch.
+	Branch string `toml:"branch,omitempty"`
+	Tag    string `toml:"tag,omitempty"`
+	Commit string `toml:"commit,omitempty"`
+	// SkipSubmodules leaves the repository's submodules uninitialised when
+	// it is cloned and updated
+	SkipSubmodules bool `toml:"skip_submodules,omitempty"`
+	// Depth makes the repository shallow, only fetching that many commits
+	// from the tip, a pinned commit has to be among them. SingleBranch only
+	// fetches the branch or tag that is cloned.
+	Depth        int  `toml:"depth,omitempty"`
+	SingleBranch bool `toml:"single_branch,omitempty"`
+	// Config is the repository's git configuration, made from its url,
+	// remotes and git keys. It is only saved by older versions of punkt,
+	// git.toml files with it are migrated when next saved.
+	Config *gitconf.Config `toml:"Config,omitempty"`
+	// Condition limits which machines the repository is cloned on
+	machine.Condition
+}
+
+// Pin describes what the repository is pinned to, it is empty if the
+// repository isn't pinned.
+func (repo Repo) Pin() string {
+	switch {
+	case repo.Commit != "":
+		return "commit " + repo.Commit
+	case repo.Tag != "":
+		return "tag " + repo.Tag
+	case repo.Branch != "":
+		return "branch " + repo.Branch
+	}
+
+	return ""
 }
 
 // Manager ...
@@ -39,6 +91,13 @@ type Config struct {
 	Repositories []Repo
 }
 
+// savedConfig is the configuration as it is stored, with the symlinks in the
+// same format as the symlink manager uses.
+type savedConfig struct {
+	Symlinks     map[string]interface{}
+	Repositories []Repo
+}
+
 // NewManager ...
 func NewManager(c conf.Config, snapshot fs.Snapshot, configFile string) *Manager {
 	return &Manager{
@@ -50,6 +109,10 @@ func NewManager(c conf.Config, snapshot fs.Snapshot, configFile string) *Manager
 	}
 }
 
+// readConfig reads the configured repositories with their paths expanded,
+// they are stored relative to the home directory where possible. A
+// git.toml with the whole git configuration of the repositories is read
+// as well, it is migrated to the compact format when it is next saved.
 func (mgr Manager) readConfig() Config {
 	var config Config
 	err := mgr.snapshot.ReadToml(&config, mgr.configFile)
@@ -57,9 +120,56 @@ func (mgr Manager) readConfig() Config {
 		return Config{}
 	}
 
+	for i, repo := range config.Repositories {
+		if repo.Config != nil {
+			repo = repo.Compact()
+		}
+
+		repo.Path = mgr.snapshot.ExpandHome(repo.Path)
+		config.Repositories[i] = repo.Expand()
+	}
+
 	return config
 }
 
+// saveConfig saves the repositories, making their paths relative to the
+// home directory so that the configuration works for other users too.
+func (mgr Manager) saveConfig(config Config) error {
+	repos := make([]Repo, len(config.Repositories))
+	for i, repo := range config.Repositories {
+		repo = repo.Compact()
+		repo.Path = mgr.unexpand(repo.Path)
+		repos[i] = repo
+	}
+
+	return mgr.snapshot.SaveToml(savedConfig{
+		Symlinks:     config.Symlinks.AsMap(),
+		Repositories: repos,
+	}, mgr.configFile)
+}
+
+// absolute expands the path, resolving it relative to the working directory
+// unless it is absolute already.
+func (mgr Manager) absolute(path string) string {
+	path = mgr.snapshot.ExpandHome(path)
+	if !filepath.IsAbs(path) {
+		path = mgr.snapshot.Fs.Join(mgr.snapshot.WorkingDir, path)
+	}
+
+	return filepath.Clean(path)
+}
+
+// unexpand replaces the home directory the path is in with ~, paths outside
+// of it are kept as they are.
+func (mgr Manager) unexpand(path string) string {
+	home := filepath.Clean(mgr.snapshot.UserHome)
+	if path == home || strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + strings.TrimPrefix(path, home)
+	}
+
+	return path
+}
+
 // Name ...
 func (mgr Manager) Name() string {
 	return "git"
@@ -74,64 +184,237 @@ func (mgr Manager) Add(path string) error {
 
 	config := mgr.readConfig()
 	config.Repositories = append(config.Repositories, *repo)
-	return mgr.snapshot.SaveToml(config, mgr.configFile)
+	return mgr.saveConfig(config)
 }
 
-// Remove ...
+// Remove removes the repository at path, which can be relative, home
+// relative or absolute, from the configuration.
 func (mgr Manager) Remove(path string) error {
 	config := mgr.readConfig()
+	index, err := mgr.index(config, path)
+	if err != nil {
+		return err
+	}
+
+	config.Repositories = append(config.Repositories[:index], config.Repositories[index+1:]...)
+	return mgr.saveConfig(config)
+}
+
+// Bump moves the pin of the repository at path to ref, keeping the kind of
+// pin it has, checks it out and saves the new pin.
+func (mgr Manager) Bump(path, ref string) error {
+	config := mgr.readConfig()
+	repo, err := mgr.find(config, path)
+	if err != nil {
+		return err
+	}
 
-	index := -1
-	for i, repo := range config.Repositories {
-		if repo.Path == path {
-			index = i
-		}
+	switch {
+	case repo.Commit != "":
+		repo.Commit = ref
+	case repo.Tag != "":
+		repo.Tag = ref
+	case repo.Branch != "":
+		repo.Branch = ref
+	default:
+		return ErrNotPinned
 	}
 
-	if index < 0 {
-		logrus.WithFields(logrus.Fields{
-			"path":   path,
-			"config": config,
-		}).Error("repository not found in config file")
-		return ErrRepositoryNotFoundInConfig
+	err = mgr.RepoManager.Checkout(*repo)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check out %s", repo.Pin())
 	}
 
-	config.Repositories = append(config.Repositories[:index], config.Repositories[index+1:]...)
-	return mgr.snapshot.SaveToml(config, mgr.configFile)
+	printer.Log.Success("pinned <fg 5>%s<reset> to %s", mgr.snapshot.UnexpandHome(repo.Path), repo.Pin())
+	return mgr.saveConfig(config)
+}
+
+// Unshallow fetches the whole history of the repository at path and saves
+// it to no longer be cloned shallow.
+func (mgr Manager) Unshallow(path string) error {
+	config := mgr.readConfig()
+	repo, err := mgr.find(config, path)
+	if err != nil {
+		return err
+	}
+
+	err = mgr.RepoManager.Unshallow(*repo)
+	if err != nil {
+		return err
+	}
+
+	repo.Depth = 0
+	printer.Log.Success("fetched the whole history of <fg 5>%s", mgr.snapshot.UnexpandHome(repo.Path))
+	return mgr.saveConfig(config)
+}
+
+// find returns the repository at path in the config, changes to it are
+// made to the config.
+func (mgr Manager) find(config Config, path string) (*Repo, error) {
+	i, err := mgr.index(config, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Repositories[i], nil
 }
 
-// Update ...
+// index returns the index of the repository at path in the config, the
+// path can be relative, home relative or absolute.
+func (mgr Manager) index(config Config, path string) (int, error) {
+	abs := mgr.absolute(path)
+	for i := range config.Repositories {
+		if filepath.Clean(config.Repositories[i].Path) == abs {
+			return i, nil
+		}
+	}
+
+	logrus.WithField("path", abs).Error("repository not found in config file")
+	return -1, ErrRepositoryNotFoundInConfig
+}
+
+// Update updates the repositories, those with local changes are reported
+// and skipped unless configured to be stashed or discarded.
 func (mgr Manager) Update() error {
 	var result error
 	for _, repo := range mgr.readConfig().Repositories {
-		_, err := mgr.RepoManager.Update(repo.Path)
+		if !mgr.applies(repo, &result) {
+			continue
+		}
+
+		updated, err := mgr.RepoManager.Update(repo, mgr.config.Dirty)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"repo": repo,
 			}).WithError(err).Error("Unable to update git repository")
 			result = multierror.Append(result, err)
+			continue
+		}
+
+		if updated.Skipped {
+			mgr.reportSkipped(repo, *updated)
 		}
 	}
 
 	return result
 }
 
-// Ensure ...
+// reportSkipped tells the user why the repository wasn't updated
+func (mgr Manager) reportSkipped(repo Repo, updated UpdateResult) {
+	item := mgr.snapshot.UnexpandHome(repo.Path)
+	printer.Log.Warning("<fg 3>%s<reset> has local changes, not updating it: %s", item, updated)
+	if len(updated.Modified) > 0 {
+		printer.Log.Note("modified: %s", strings.Join(updated.Modified, ", "))
+	}
+	if len(updated.Untracked) > 0 {
+		printer.Log.Note("untracked: %s", strings.Join(updated.Untracked, ", "))
+	}
+
+	printer.Log.Event(printer.Event{
+		Manager:   mgr.Name(),
+		Operation: "update",
+		Item:      item,
+		Result:    printer.ResultSkipped,
+		Message:   updated.String(),
+	})
+}
+
+// Ensure clones the repositories that are missing, and warns about those
+// that have something else than what they are pinned to checked out.
 func (mgr Manager) Ensure() error {
 	var result error
 	for _, repo := range mgr.readConfig().Repositories {
+		if !mgr.applies(repo, &result) {
+			continue
+		}
+
 		err := mgr.RepoManager.Ensure(repo)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"repo": repo,
 			}).WithError(err).Error("Failed to ensure git repository")
 			result = multierror.Append(result, err)
+			continue
+		}
+
+		if repo.Pin() == "" {
+			continue
+		}
+
+		checkout, err := mgr.RepoManager.Drifted(repo)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"repo": repo,
+			}).WithError(err).Error("Failed to compare git repository with its pin")
+			result = multierror.Append(result, err)
+			continue
+		}
+
+		if checkout != "" {
+			printer.Log.Warning("<fg 3>%s<reset> has %s checked out, not the pinned %s",
+				mgr.snapshot.UnexpandHome(repo.Path), checkout, repo.Pin())
 		}
 	}
 
 	return result
 }
 
+// Status reports the configured repositories that are missing, have
+// uncommitted changes, are behind their remote or have drifted from their
+// pin.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	var result error
+	var drifts []drift.Drift
+	for _, repo := range mgr.readConfig().Repositories {
+		if !mgr.applies(repo, &result) {
+			continue
+		}
+
+		status, err := mgr.RepoManager.Status(repo)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"repo": repo,
+			}).WithError(err).Error("Failed to get status of git repository")
+			result = multierror.Append(result, err)
+			continue
+		}
+
+		item := mgr.snapshot.UnexpandHome(repo.Path)
+		if !status.Exists {
+			drifts = append(drifts, drift.New(item, "repository is missing"))
+			continue
+		}
+
+		if !status.Clean {
+			drifts = append(drifts, drift.New(item, "repository has uncommitted changes"))
+		}
+
+		if status.Behind > 0 {
+			drifts = append(drifts, drift.New(item, "repository is %d commits behind its remote", status.Behind))
+		}
+
+		if status.Drifted != "" {
+			drifts = append(drifts, drift.New(item, "repository has %s checked out, not the pinned %s", status.Drifted, repo.Pin()))
+		}
+	}
+
+	return drifts, result
+}
+
+// applies checks if the repository's condition matches the machine, adding
+// the error to result if it can't be checked.
+func (mgr Manager) applies(repo Repo, result *error) bool {
+	ok, err := repo.Applies(mgr.snapshot.UnexpandHome(repo.Path))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"repo": repo,
+		}).WithError(err).Error("Failed to check the condition of git repository")
+		*result = multierror.Append(*result, err)
+	}
+
+	return ok
+}
+
 // Dump ...
 func (mgr Manager) Dump() (string, error) {
 	configFiles := globalConfigFiles()
@@ -148,13 +431,9 @@ func (mgr Manager) Dump() (string, error) {
 		}).Debug("Storing symlink to config file")
 	}
 
-	// TODO: encode symlinks as a map instead
-	config := struct {
-		Symlinks     map[string]string
-		Repositories []Repo
-	}{
-		symlink.Config{Symlinks: symlinks}.AsMap(),
-		[]Repo{},
+	config := savedConfig{
+		Symlinks:     symlink.Config{Symlinks: symlinks}.AsMap(),
+		Repositories: []Repo{},
 	}
 
 	var out bytes.Buffer
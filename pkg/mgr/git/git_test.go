@@ -1,7 +1,10 @@
 package git_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -9,12 +12,18 @@ import (
 	"github.com/BurntSushi/toml"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
+	goGit "gopkg.in/src-d/go-git.v4"
+	gitconf "gopkg.in/src-d/go-git.v4/config"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr/git"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/printer"
 	"github.com/mbark/punkt/pkg/run"
 	"github.com/mbark/punkt/testmock"
 )
@@ -38,11 +47,60 @@ func (m *mockRepoManager) Ensure(repo git.Repo) error {
 	return args.Error(0)
 }
 
-func (m *mockRepoManager) Update(dir string) (bool, error) {
+func (m *mockRepoManager) Update(repo git.Repo, dirty string) (*git.UpdateResult, error) {
+	args := m.Called(repo, dirty)
+	result, _ := args.Get(0).(*git.UpdateResult)
+	return result, args.Error(1)
+}
+
+func (m *mockRepoManager) LocalChanges(dir string) ([]string, []string, error) {
+	args := m.Called(dir)
+	modified, _ := args.Get(0).([]string)
+	untracked, _ := args.Get(1).([]string)
+	return modified, untracked, args.Error(2)
+}
+
+func (m *mockRepoManager) Checkout(repo git.Repo) error {
+	args := m.Called(repo)
+	return args.Error(0)
+}
+
+func (m *mockRepoManager) Unshallow(repo git.Repo) error {
+	args := m.Called(repo)
+	return args.Error(0)
+}
+
+func (m *mockRepoManager) Drifted(repo git.Repo) (string, error) {
+	args := m.Called(repo)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockRepoManager) Status(repo git.Repo) (*git.RepoStatus, error) {
+	args := m.Called(repo)
+	status, _ := args.Get(0).(*git.RepoStatus)
+	return status, args.Error(1)
+}
+
+func (m *mockRepoManager) Init(dir string) error {
 	args := m.Called(dir)
+	return args.Error(0)
+}
+
+func (m *mockRepoManager) Clone(url, dir string) error {
+	args := m.Called(url, dir)
+	return args.Error(0)
+}
+
+func (m *mockRepoManager) Commit(dir, message string, include func(file string) bool) (bool, error) {
+	args := m.Called(dir, message, include)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *mockRepoManager) Sync(dir string) error {
+	args := m.Called(dir)
+	return args.Error(0)
+}
+
 var _ = Describe("Git: Manager", func() {
 	var snapshot fs.Snapshot
 	var config conf.Config
@@ -67,6 +125,13 @@ var _ = Describe("Git: Manager", func() {
 		Expect(mgr.Name()).To(Equal("git"))
 	})
 
+	It("should describe what a repository is pinned to", func() {
+		Expect(git.Repo{}.Pin()).To(BeEmpty())
+		Expect(git.Repo{Branch: "main"}.Pin()).To(Equal("branch main"))
+		Expect(git.Repo{Branch: "main", Tag: "v1.0"}.Pin()).To(Equal("tag v1.0"))
+		Expect(git.Repo{Tag: "v1.0", Commit: "abc"}.Pin()).To(Equal("commit abc"))
+	})
+
 	var _ = Context("Dump", func() {
 		It("should return valid toml", func() {
 			dumped, err := mgr.Dump()
@@ -134,6 +199,43 @@ var _ = Describe("Git: Manager", func() {
 
 			Expect(mgr.Ensure()).NotTo(Succeed())
 		})
+
+		It("should skip the repos whose condition doesn't match", func() {
+			machine.Hostname = func() (string, error) { return "laptop", nil }
+			c := git.Config{Repositories: []git.Repo{
+				{Path: "/work", Condition: machine.Condition{Host: "work-*"}},
+				{Path: "/home", Condition: machine.Condition{Host: "lap*"}},
+			}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+
+			Expect(mgr.Ensure()).To(Succeed())
+			repoMgr.AssertNumberOfCalls(GinkgoT(), "Ensure", 1)
+			repoMgr.AssertCalled(GinkgoT(), "Ensure", c.Repositories[1])
+		})
+
+		It("should only compare pinned repos with their pin", func() {
+			c := git.Config{Repositories: []git.Repo{
+				{Path: "/pinned", Tag: "v1.0"},
+				{Path: "/unpinned"},
+			}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+			repoMgr.On("Drifted", mock.Anything).Return("branch master", nil)
+
+			Expect(mgr.Ensure()).To(Succeed())
+			repoMgr.AssertNumberOfCalls(GinkgoT(), "Drifted", 1)
+			repoMgr.AssertCalled(GinkgoT(), "Drifted", c.Repositories[0])
+		})
+
+		It("should fail if a repo can't be compared with its pin", func() {
+			c := git.Config{Repositories: []git.Repo{{Path: "/pinned", Tag: "v1.0"}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+			repoMgr.On("Drifted", mock.Anything).Return("", fmt.Errorf("fail"))
+
+			Expect(mgr.Ensure()).NotTo(Succeed())
+		})
 	})
 
 	var _ = Context("Update", func() {
@@ -150,12 +252,520 @@ var _ = Describe("Git: Manager", func() {
 		})
 
 		It("should fail if some repos can't be updated", func() {
-			repoMgr.On("Update", mock.Anything).Return(false, fmt.Errorf("fail"))
+			repoMgr.On("Update", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("fail"))
 			dir := addFakeRepo(config, snapshot, "repo")
 			Expect(mgr.Add(dir)).To(Succeed())
 
 			Expect(mgr.Update()).NotTo(Succeed())
 		})
+
+		It("should update with the configured strategy for local changes", func() {
+			config.Dirty = git.DirtyStash
+			mgr = git.NewManager(config, snapshot, configFile)
+			mgr.RepoManager = repoMgr
+			c := git.Config{Repositories: []git.Repo{{Path: "/repo"}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Update", mock.Anything, mock.Anything).Return(&git.UpdateResult{Updated: true}, nil)
+
+			Expect(mgr.Update()).To(Succeed())
+			repoMgr.AssertCalled(GinkgoT(), "Update", c.Repositories[0], git.DirtyStash)
+		})
+
+		It("should report the repos skipped for their local changes", func() {
+			Expect(printer.Log.SetFormat(printer.FormatJSONL)).To(Succeed())
+			events := new(bytes.Buffer)
+			printer.Log.EventsOut = events
+			printer.Log.Out = ioutil.Discard
+			defer func() { Expect(printer.Log.SetFormat(printer.FormatText)).To(Succeed()) }()
+
+			c := git.Config{Repositories: []git.Repo{{Path: filepath.Join(snapshot.UserHome, "repo")}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Update", mock.Anything, mock.Anything).Return(&git.UpdateResult{
+				Skipped:  true,
+				Modified: []string{"file"},
+				Behind:   2,
+			}, nil)
+
+			Expect(mgr.Update()).To(Succeed())
+
+			var event printer.Event
+			Expect(json.Unmarshal(events.Bytes(), &event)).To(Succeed())
+			Expect(event).To(Equal(printer.Event{
+				Manager:   "git",
+				Operation: "update",
+				Item:      "~/repo",
+				Result:    printer.ResultSkipped,
+				Message:   "1 modified and 0 untracked files, 0 commits ahead and 2 behind its remote",
+			}))
+		})
+	})
+
+	var _ = Context("Status", func() {
+		BeforeEach(func() {
+			c := git.Config{Repositories: []git.Repo{{Path: filepath.Join(snapshot.UserHome, "repo")}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+		})
+
+		It("should report nothing if the repositories are up to date", func() {
+			repoMgr.On("Status", mock.Anything).Return(&git.RepoStatus{Exists: true, Clean: true}, nil)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should report missing repositories", func() {
+			repoMgr.On("Status", mock.Anything).Return(&git.RepoStatus{}, nil)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(HaveLen(1))
+			Expect(drifts[0].Item).To(Equal("~/repo"))
+		})
+
+		It("should report dirty repositories that are behind", func() {
+			repoMgr.On("Status", mock.Anything).Return(&git.RepoStatus{Exists: true, Behind: 2}, nil)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(HaveLen(2))
+		})
+
+		It("should report repositories that have drifted from their pin", func() {
+			c := git.Config{Repositories: []git.Repo{{Path: filepath.Join(snapshot.UserHome, "repo"), Branch: "main"}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Status", mock.Anything).Return(&git.RepoStatus{Exists: true, Clean: true, Drifted: "branch dev"}, nil)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(drift.New("~/repo", "repository has branch dev checked out, not the pinned branch main")))
+		})
+
+		It("should fail if the status can't be determined", func() {
+			repoMgr.On("Status", mock.Anything).Return(nil, fmt.Errorf("fail"))
+
+			_, err := mgr.Status()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	var _ = Context("Bump", func() {
+		var repoPath string
+
+		BeforeEach(func() {
+			repoPath = filepath.Join(snapshot.UserHome, "repo")
+			c := git.Config{Repositories: []git.Repo{{Path: repoPath, Tag: "v1.0"}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+		})
+
+		It("should check out and save the new pin", func() {
+			repoMgr.On("Checkout", mock.Anything).Return(nil)
+
+			Expect(mgr.Bump("~/repo", "v2.0")).To(Succeed())
+			repoMgr.AssertCalled(GinkgoT(), "Checkout", git.Repo{Path: repoPath, Tag: "v2.0"})
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(Equal([]git.Repo{{Path: "~/repo", Tag: "v2.0"}}))
+		})
+
+		It("should not save the pin if it can't be checked out", func() {
+			repoMgr.On("Checkout", mock.Anything).Return(fmt.Errorf("fail"))
+
+			Expect(mgr.Bump(repoPath, "v2.0")).NotTo(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories[0].Tag).To(Equal("v1.0"))
+		})
+
+		It("should fail for repositories that aren't pinned", func() {
+			c := git.Config{Repositories: []git.Repo{{Path: repoPath}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+
+			Expect(mgr.Bump(repoPath, "v2.0")).To(Equal(git.ErrNotPinned))
+		})
+
+		It("should fail for repositories that aren't configured", func() {
+			Expect(mgr.Bump("/non/existant", "v2.0")).To(Equal(git.ErrRepositoryNotFoundInConfig))
+		})
+	})
+
+	var _ = Context("Unshallow", func() {
+		var repoPath string
+
+		BeforeEach(func() {
+			repoPath = filepath.Join(snapshot.UserHome, "repo")
+			c := git.Config{Repositories: []git.Repo{{Path: repoPath, Depth: 1, SingleBranch: true}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+		})
+
+		It("should fetch the whole history and stop cloning shallow", func() {
+			repoMgr.On("Unshallow", mock.Anything).Return(nil)
+
+			Expect(mgr.Unshallow("~/repo")).To(Succeed())
+			repoMgr.AssertCalled(GinkgoT(), "Unshallow", git.Repo{Path: repoPath, Depth: 1, SingleBranch: true})
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(Equal([]git.Repo{{Path: "~/repo", SingleBranch: true}}))
+		})
+
+		It("should keep the depth if the history can't be fetched", func() {
+			repoMgr.On("Unshallow", mock.Anything).Return(fmt.Errorf("fail"))
+
+			Expect(mgr.Unshallow(repoPath)).NotTo(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories[0].Depth).To(Equal(1))
+		})
+	})
+
+	var _ = Context("Paths", func() {
+		BeforeEach(func() {
+			repoMgr = new(mockRepoManager)
+			mgr.RepoManager = repoMgr
+		})
+
+		It("should store the paths relative to the home directory", func() {
+			repoPath := filepath.Join(snapshot.UserHome, "repo")
+			repoMgr.On("Dump", repoPath).Return(&git.Repo{Path: repoPath}, nil)
+			Expect(mgr.Add(repoPath)).To(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories[0].Path).To(Equal("~/repo"))
+		})
+
+		It("should keep the paths outside of the home directory absolute", func() {
+			repoMgr.On("Dump", "/opt/repo").Return(&git.Repo{Path: "/opt/repo"}, nil)
+			repoMgr.On("Dump", "/homeless/repo").Return(&git.Repo{Path: "/homeless/repo"}, nil)
+			Expect(mgr.Add("/opt/repo")).To(Succeed())
+			Expect(mgr.Add("/homeless/repo")).To(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories[0].Path).To(Equal("/opt/repo"))
+			Expect(actual.Repositories[1].Path).To(Equal("/homeless/repo"))
+		})
+
+		It("should expand the paths for the repository manager", func() {
+			repoPath := filepath.Join(snapshot.UserHome, "repo")
+			c := git.Config{Repositories: []git.Repo{{Path: "~/repo"}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+
+			Expect(mgr.Ensure()).To(Succeed())
+			repoMgr.AssertCalled(GinkgoT(), "Ensure", git.Repo{Path: repoPath})
+		})
+	})
+
+	var _ = Context("Compact format", func() {
+		var repoPath string
+
+		dumped := func() *git.Repo {
+			c := gitconf.NewConfig()
+			c.Remotes[goGit.DefaultRemoteName] = &gitconf.RemoteConfig{Name: goGit.DefaultRemoteName, URLs: []string{"https://github.com/mbark/punkt"}}
+			c.Remotes["upstream"] = &gitconf.RemoteConfig{Name: "upstream", URLs: []string{"https://github.com/other/punkt"}}
+			c.Raw.Section("user").SetOption("email", "me@example.com")
+			c.Raw.Section("alias").SetOption("co", "checkout")
+			return &git.Repo{Name: "repo", Path: repoPath, Config: c}
+		}
+
+		BeforeEach(func() {
+			repoPath = filepath.Join(snapshot.UserHome, "repo")
+			repoMgr = new(mockRepoManager)
+			mgr.RepoManager = repoMgr
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+		})
+
+		It("should save the url, remotes and git keys instead of the git config", func() {
+			repoMgr.On("Dump", repoPath).Return(dumped(), nil)
+			Expect(mgr.Add(repoPath)).To(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(Equal([]git.Repo{{
+				Name:    "repo",
+				Path:    "~/repo",
+				URL:     "https://github.com/mbark/punkt",
+				Remotes: map[string]string{"upstream": "https://github.com/other/punkt"},
+				Git:     map[string]string{"user.email": "me@example.com"},
+			}}))
+		})
+
+		It("should read a git.toml with the git config without changing it", func() {
+			legacy := git.Config{Repositories: []git.Repo{*dumped()}}
+			Expect(snapshot.SaveToml(&legacy, configFile)).To(Succeed())
+			before, err := snapshot.Read(configFile)
+			Expect(err).To(BeNil())
+			Expect(before).To(ContainSubstring("[Repositories.Config"))
+
+			Expect(mgr.Ensure()).To(Succeed())
+
+			after, err := snapshot.Read(configFile)
+			Expect(err).To(BeNil())
+			Expect(after).To(Equal(before))
+
+			ensured := repoMgr.Calls[0].Arguments.Get(0).(git.Repo)
+			Expect(ensured.Config.Remotes[goGit.DefaultRemoteName].URLs).To(Equal([]string{"https://github.com/mbark/punkt"}))
+			Expect(ensured.Config.Remotes["upstream"].URLs).To(Equal([]string{"https://github.com/other/punkt"}))
+		})
+
+		It("should migrate a git.toml with the git config when saving it", func() {
+			legacy := git.Config{Repositories: []git.Repo{*dumped()}}
+			Expect(snapshot.SaveToml(&legacy, configFile)).To(Succeed())
+
+			other := filepath.Join(snapshot.UserHome, "other")
+			repoMgr.On("Dump", other).Return(&git.Repo{Path: other}, nil)
+			Expect(mgr.Add(other)).To(Succeed())
+
+			content, err := snapshot.Read(configFile)
+			Expect(err).To(BeNil())
+			Expect(content).NotTo(ContainSubstring("Config"))
+			Expect(content).To(ContainSubstring(`url = "https://github.com/mbark/punkt"`))
+		})
+
+		It("should keep what was written by hand when saving", func() {
+			written := `[[Repositories]]
+  path = "~/repo"
+  url = "git@github.com:mbark/punkt.git"
+  tag = "v1.0"
+  [Repositories.remotes]
+    upstream = "https://github.com/other/punkt"
+  [Repositories.git]
+    "alias.co" = "checkout"
+    "user.email" = "me@example.com"
+`
+			Expect(snapshot.Save(written, configFile)).To(Succeed())
+			repoMgr.On("Checkout", mock.Anything).Return(nil)
+
+			Expect(mgr.Bump("~/repo", "v1.0")).To(Succeed())
+
+			var expected, actual git.Config
+			Expect(toml.Unmarshal([]byte(written), &expected)).To(Succeed())
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual).To(Equal(expected))
+		})
+
+		It("should keep the symlinks when saving", func() {
+			written := `[Symlinks]
+  "~/.gitconfig" = "~/.dotfiles/.gitconfig"
+  "~/.gitignore" = { target = "~/.dotfiles/.gitignore", copy = true }
+
+[[Repositories]]
+  path = "~/a"
+  url = "https://github.com/mbark/a"
+
+[[Repositories]]
+  path = "~/b"
+  url = "https://github.com/mbark/b"
+`
+			Expect(snapshot.Save(written, configFile)).To(Succeed())
+
+			Expect(mgr.Remove("~/b")).To(Succeed())
+
+			var expected, actual git.Config
+			Expect(toml.Unmarshal([]byte(written), &expected)).To(Succeed())
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Symlinks).To(Equal(expected.Symlinks))
+			Expect(actual.Repositories).To(Equal(expected.Repositories[:1]))
+		})
+	})
+
+	var _ = Context("Scan", func() {
+		var code string
+
+		withRemote := func(path string) *git.Repo {
+			c := gitconf.NewConfig()
+			c.Remotes[goGit.DefaultRemoteName] = &gitconf.RemoteConfig{
+				Name: goGit.DefaultRemoteName,
+				URLs: []string{"https://github.com/mbark/" + filepath.Base(path)},
+			}
+			return &git.Repo{Name: filepath.Base(path), Path: path, Config: c}
+		}
+
+		workingCopy := func(path string) string {
+			path = filepath.Join(code, path)
+			Expect(snapshot.Fs.MkdirAll(filepath.Join(path, ".git"), 0755)).To(Succeed())
+			repoMgr.On("Dump", path).Return(withRemote(path), nil)
+			return path
+		}
+
+		BeforeEach(func() {
+			code = filepath.Join(snapshot.UserHome, "code")
+			repoMgr = new(mockRepoManager)
+			mgr.RepoManager = repoMgr
+		})
+
+		It("should add the working copies it finds", func() {
+			first := workingCopy("first")
+			second := workingCopy("mbark/second")
+
+			added, err := mgr.Scan("~/code", 3, nil)
+			Expect(err).To(BeNil())
+			Expect(added).To(Equal([]string{first, second}))
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(HaveLen(2))
+			Expect(actual.Repositories[0].Path).To(Equal("~/code/first"))
+			Expect(actual.Repositories[1].Path).To(Equal("~/code/mbark/second"))
+		})
+
+		It("should scan a directory relative to the working directory", func() {
+			first := workingCopy("first")
+
+			relative, err := filepath.Rel(snapshot.WorkingDir, code)
+			Expect(err).To(BeNil())
+			added, err := mgr.Scan(relative, 3, nil)
+			Expect(err).To(BeNil())
+			Expect(added).To(Equal([]string{first}))
+		})
+
+		It("should not add the repositories already configured", func() {
+			existing := workingCopy("existing")
+			added := workingCopy("added")
+			c := git.Config{Repositories: []git.Repo{{Path: existing}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+
+			Expect(mgr.Scan(code, 3, nil)).To(Equal([]string{added}))
+			repoMgr.AssertNotCalled(GinkgoT(), "Dump", existing)
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(HaveLen(2))
+		})
+
+		It("should skip working copies without a remote", func() {
+			local := filepath.Join(code, "local")
+			Expect(snapshot.Fs.MkdirAll(filepath.Join(local, ".git"), 0755)).To(Succeed())
+			repoMgr.On("Dump", local).Return(&git.Repo{Path: local, Config: gitconf.NewConfig()}, nil)
+
+			added, err := mgr.Scan(code, 3, nil)
+			Expect(err).To(BeNil())
+			Expect(added).To(BeEmpty())
+
+			_, err = snapshot.Fs.Stat(configFile)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should not look deeper than the max depth", func() {
+			shallow := workingCopy("a/shallow")
+			workingCopy("a/b/deep")
+
+			Expect(mgr.Scan(code, 2, nil)).To(Equal([]string{shallow}))
+		})
+
+		It("should not walk into working copies", func() {
+			outer := workingCopy("outer")
+			workingCopy("outer/inner")
+
+			Expect(mgr.Scan(code, 3, nil)).To(Equal([]string{outer}))
+		})
+
+		It("should not walk into ignored directories", func() {
+			kept := workingCopy("kept")
+			workingCopy("web/node_modules/dependency")
+			workingCopy("archive/old")
+
+			Expect(mgr.Scan(code, 3, []string{"node_modules", "archive/*"})).To(Equal([]string{kept}))
+		})
+
+		It("should fail for invalid ignore patterns", func() {
+			_, err := mgr.Scan(code, 3, []string{"["})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should add the other repositories if some can't be dumped", func() {
+			failing := filepath.Join(code, "failing")
+			Expect(snapshot.Fs.MkdirAll(filepath.Join(failing, ".git"), 0755)).To(Succeed())
+			repoMgr.On("Dump", failing).Return(new(git.Repo), fmt.Errorf("fail"))
+			added := workingCopy("working")
+
+			repos, err := mgr.Scan(code, 3, nil)
+			Expect(err).NotTo(BeNil())
+			Expect(repos).To(Equal([]string{added}))
+		})
+	})
+
+	var _ = Context("AddURL", func() {
+		var repoPath string
+
+		BeforeEach(func() {
+			repoPath = filepath.Join(snapshot.UserHome, "code", "github.com", "mbark", "punkt")
+		})
+
+		It("should clone the repository into its checkout path and add it", func() {
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+
+			path, err := mgr.AddURL("git@github.com:mbark/punkt.git")
+			Expect(err).To(BeNil())
+			Expect(path).To(Equal(repoPath))
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(HaveLen(1))
+			Expect(actual.Repositories[0].Path).To(Equal("~/code/github.com/mbark/punkt"))
+			Expect(actual.Repositories[0].URL).To(Equal("git@github.com:mbark/punkt.git"))
+
+			cloned := repoMgr.Calls[0].Arguments.Get(0).(git.Repo)
+			Expect(cloned.Path).To(Equal(repoPath))
+			Expect(cloned.Config.Remotes[goGit.DefaultRemoteName].URLs).To(Equal([]string{"git@github.com:mbark/punkt.git"}))
+		})
+
+		It("should clone below the configured root", func() {
+			config.RepositoryRoot = "~/src"
+			mgr = git.NewManager(config, snapshot, configFile)
+			mgr.RepoManager = repoMgr
+			repoMgr.On("Ensure", mock.Anything).Return(nil)
+
+			Expect(mgr.AddURL("https://github.com/mbark/punkt")).To(Equal(filepath.Join(snapshot.UserHome, "src", "github.com", "mbark", "punkt")))
+		})
+
+		It("should not add the repository twice", func() {
+			c := git.Config{Repositories: []git.Repo{{Path: repoPath}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+
+			Expect(mgr.AddURL("https://github.com/mbark/punkt.git")).To(Equal(repoPath))
+			repoMgr.AssertNotCalled(GinkgoT(), "Ensure", mock.Anything)
+		})
+
+		It("should not add the repository if it can't be cloned", func() {
+			repoMgr.On("Ensure", mock.Anything).Return(fmt.Errorf("fail"))
+
+			_, err := mgr.AddURL("https://github.com/mbark/punkt.git")
+			Expect(err).NotTo(BeNil())
+
+			_, err = snapshot.Fs.Stat(configFile)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should make the checkout path from the url's host and path", func() {
+			for _, url := range []string{
+				"https://github.com/mbark/punkt.git",
+				"ssh://git@github.com:22/mbark/punkt.git",
+				"git@github.com:mbark/punkt",
+				"github.com:mbark/punkt/",
+			} {
+				Expect(git.CheckoutPath("/code", url)).To(Equal("/code/github.com/mbark/punkt"), url)
+			}
+		})
+
+		It("should fail to make a checkout path from an invalid url", func() {
+			for _, url := range []string{"https://github.com", "https://github.com/../etc", "punkt"} {
+				_, err := git.CheckoutPath("/code", url)
+				Expect(errors.Cause(err)).To(Equal(git.ErrInvalidURL), url)
+			}
+		})
+
+		It("should tell urls and paths apart", func() {
+			Expect(git.IsURL("https://github.com/mbark/punkt")).To(BeTrue())
+			Expect(git.IsURL("git@github.com:mbark/punkt.git")).To(BeTrue())
+			Expect(git.IsURL("~/code/punkt")).To(BeFalse())
+			Expect(git.IsURL("/home/code/punkt")).To(BeFalse())
+		})
 	})
 
 	var _ = Context("when removing a git repo", func() {
@@ -175,6 +785,21 @@ var _ = Describe("Git: Manager", func() {
 			Expect(actual.Repositories).To(BeEmpty())
 		})
 
+		It("should remove a repo given a relative or home relative path", func() {
+			c := git.Config{Repositories: []git.Repo{{Path: "~/first"}, {Path: filepath.Join(snapshot.UserHome, "second")}}}
+			Expect(snapshot.SaveToml(&c, configFile)).To(Succeed())
+
+			relative, err := filepath.Rel(snapshot.WorkingDir, filepath.Join(snapshot.UserHome, "first"))
+			Expect(err).To(BeNil())
+
+			Expect(mgr.Remove("~/second")).To(Succeed())
+			Expect(mgr.Remove(relative)).To(Succeed())
+
+			var actual git.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Repositories).To(BeEmpty())
+		})
+
 		It("should return an error if the repo doesn't exist", func() {
 			repoPath := filepath.Join(snapshot.UserHome, "repo")
 			c := git.Config{Repositories: []git.Repo{{Path: repoPath}}}
@@ -200,7 +825,7 @@ func TestGitHelperProcess(t *testing.T) {
 		}
 
 		if os.Getenv("WITH_GITCONFIG") == "true" {
-			fmt.Println(gitConfig)
+			fmt.Print(gitConfig)
 		} else {
 			fmt.Println(``)
 		}
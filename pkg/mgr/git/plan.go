@@ -0,0 +1,140 @@
+package git
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/plan"
+)
+
+// plannedRepoManager records the clones, inits, checkouts, commits, pulls and
+// pushes that would be made in the plan instead of making them, everything
+// else is delegated.
+type plannedRepoManager struct {
+	RepoManager
+	plan *plan.Plan
+}
+
+// NewPlannedRepoManager wraps the given RepoManager, recording clones and
+// updates in the plan rather than performing them
+func NewPlannedRepoManager(p *plan.Plan, mgr RepoManager) RepoManager {
+	return plannedRepoManager{
+		RepoManager: mgr,
+		plan:        p,
+	}
+}
+
+// Ensure ...
+func (mgr plannedRepoManager) Ensure(repo Repo) error {
+	if _, err := mgr.Dump(repo.Path); err == nil {
+		logrus.WithField("repo", repo.Path).Debug("Repository already exists, nothing to plan")
+		return nil
+	}
+
+	remote, err := origin(repo)
+	if err != nil {
+		return err
+	}
+
+	mgr.plan.Record("clone", remote, "->", repo.Path)
+	if pin := repo.Pin(); pin != "" {
+		mgr.plan.Record("checkout", pin, "in", repo.Path)
+	}
+
+	return nil
+}
+
+// Init ...
+func (mgr plannedRepoManager) Init(dir string) error {
+	if _, err := mgr.Dump(dir); err == nil {
+		logrus.WithField("repo", dir).Debug("Repository already exists, nothing to plan")
+		return nil
+	}
+
+	mgr.plan.Record("git init", dir)
+	return nil
+}
+
+// Clone ...
+func (mgr plannedRepoManager) Clone(url, dir string) error {
+	if _, err := mgr.Dump(dir); err == nil {
+		logrus.WithField("repo", dir).Debug("Repository already exists, nothing to plan")
+		return nil
+	}
+
+	mgr.plan.Record("clone", url, "->", dir)
+	return nil
+}
+
+// Commit ...
+func (mgr plannedRepoManager) Commit(dir, message string, include func(file string) bool) (bool, error) {
+	mgr.plan.Record("commit", dir, message)
+	return true, nil
+}
+
+// Sync ...
+func (mgr plannedRepoManager) Sync(dir string) error {
+	mgr.plan.Record("pull", dir)
+	mgr.plan.Record("push", dir)
+	return nil
+}
+
+// Update plans to pull the repository, repositories with local changes are
+// skipped or have them stashed or discarded first the same way as when
+// updating.
+func (mgr plannedRepoManager) Update(repo Repo, dirty string) (*UpdateResult, error) {
+	if repo.Commit != "" || repo.Tag != "" {
+		logrus.WithField("repo", repo.Path).Debug("Repository is pinned, nothing to plan")
+		return &UpdateResult{}, nil
+	}
+
+	modified, untracked, err := mgr.LocalChanges(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateResult{Modified: modified, Untracked: untracked}
+	if len(modified) > 0 || len(untracked) > 0 {
+		switch dirty {
+		case "", DirtySkip:
+			result.Skipped = true
+			return result, nil
+		case DirtyStash:
+			mgr.plan.Record("stash", repo.Path)
+			result.Stashed = true
+		case DirtyForce:
+			mgr.plan.Record("discard", repo.Path)
+		default:
+			return nil, errors.Wrapf(ErrUnknownDirtyStrategy, "%s", dirty)
+		}
+	}
+
+	mgr.plan.Record("pull", repo.Path)
+	if result.Stashed {
+		mgr.plan.Record("unstash", repo.Path)
+	}
+
+	return result, nil
+}
+
+// Checkout ...
+func (mgr plannedRepoManager) Checkout(repo Repo) error {
+	mgr.plan.Record("checkout", repo.Pin(), "in", repo.Path)
+	return nil
+}
+
+// Unshallow ...
+func (mgr plannedRepoManager) Unshallow(repo Repo) error {
+	mgr.plan.Record("unshallow", repo.Path)
+	return nil
+}
+
+// Drifted ...
+func (mgr plannedRepoManager) Drifted(repo Repo) (string, error) {
+	if _, err := mgr.Dump(repo.Path); err != nil {
+		logrus.WithField("repo", repo.Path).Debug("Repository would be cloned, nothing has drifted")
+		return "", nil
+	}
+
+	return mgr.RepoManager.Drifted(repo)
+}
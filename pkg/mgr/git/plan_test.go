@@ -0,0 +1,148 @@
+package git_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	goGit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/plan"
+)
+
+var _ = Describe("Git: Planned Repo Manager", func() {
+	var p *plan.Plan
+	var repoMgr *mockRepoManager
+	var mgr git.RepoManager
+
+	BeforeEach(func() {
+		p = plan.New()
+		repoMgr = new(mockRepoManager)
+		mgr = git.NewPlannedRepoManager(p, repoMgr)
+	})
+
+	It("should plan to clone repositories that don't exist", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+		repo := git.Repo{Path: "/repo", Config: &config.Config{
+			Remotes: map[string]*config.RemoteConfig{
+				goGit.DefaultRemoteName: {Name: goGit.DefaultRemoteName, URLs: []string{"/origin"}},
+			},
+		}}
+
+		Expect(mgr.Ensure(repo)).To(Succeed())
+
+		repoMgr.AssertNotCalled(GinkgoT(), "Ensure", mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "clone", Args: []string{"/origin", "->", "/repo"}}))
+	})
+
+	It("should plan nothing for repositories that exist", func() {
+		repoMgr.On("Dump", "/repo").Return(new(git.Repo), nil)
+
+		Expect(mgr.Ensure(git.Repo{Path: "/repo"})).To(Succeed())
+		Expect(p.Operations).To(BeEmpty())
+	})
+
+	It("should plan to check out the pin of cloned repositories", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+
+		Expect(mgr.Ensure(git.Repo{Path: "/repo", URL: "/origin", Tag: "v1.0"}.Expand())).To(Succeed())
+		Expect(p.Operations).To(ContainElement(plan.Operation{Kind: "checkout", Args: []string{"tag v1.0", "in", "/repo"}}))
+	})
+
+	It("should fail to plan a clone of repositories without a url", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+
+		Expect(mgr.Ensure(git.Repo{Path: "/repo"})).NotTo(Succeed())
+		Expect(p.Operations).To(BeEmpty())
+	})
+
+	It("should plan to pull when updating", func() {
+		repoMgr.On("LocalChanges", "/repo").Return(nil, nil, nil)
+		updated, err := mgr.Update(git.Repo{Path: "/repo"}, git.DirtySkip)
+
+		Expect(err).To(BeNil())
+		Expect(updated.Updated).To(BeFalse())
+		repoMgr.AssertNotCalled(GinkgoT(), "Update", mock.Anything, mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "pull", Args: []string{"/repo"}}))
+	})
+
+	It("should plan to skip repositories with local changes when updating", func() {
+		repoMgr.On("LocalChanges", "/repo").Return([]string{"file"}, nil, nil)
+		updated, err := mgr.Update(git.Repo{Path: "/repo"}, git.DirtySkip)
+
+		Expect(err).To(BeNil())
+		Expect(updated.Skipped).To(BeTrue())
+		Expect(updated.Modified).To(Equal([]string{"file"}))
+		Expect(p.Operations).To(BeEmpty())
+	})
+
+	It("should plan to stash or discard the local changes when told to", func() {
+		repoMgr.On("LocalChanges", "/repo").Return(nil, []string{"new"}, nil)
+
+		_, err := mgr.Update(git.Repo{Path: "/repo"}, git.DirtyStash)
+		Expect(err).To(BeNil())
+		_, err = mgr.Update(git.Repo{Path: "/repo"}, git.DirtyForce)
+		Expect(err).To(BeNil())
+
+		Expect(p.Operations).To(Equal([]plan.Operation{
+			{Kind: "stash", Args: []string{"/repo"}},
+			{Kind: "pull", Args: []string{"/repo"}},
+			{Kind: "unstash", Args: []string{"/repo"}},
+			{Kind: "discard", Args: []string{"/repo"}},
+			{Kind: "pull", Args: []string{"/repo"}},
+		}))
+	})
+
+	It("should fail to plan updating a repository whose changes can't be read", func() {
+		repoMgr.On("LocalChanges", "/repo").Return(nil, nil, fmt.Errorf("fail"))
+
+		_, err := mgr.Update(git.Repo{Path: "/repo"}, git.DirtySkip)
+		Expect(err).NotTo(BeNil())
+		Expect(p.Operations).To(BeEmpty())
+	})
+
+	It("should plan nothing when updating repositories pinned to a commit", func() {
+		_, err := mgr.Update(git.Repo{Path: "/repo", Commit: "abc"}, git.DirtySkip)
+
+		Expect(err).To(BeNil())
+		Expect(p.Operations).To(BeEmpty())
+	})
+
+	It("should plan to check out a bumped pin", func() {
+		Expect(mgr.Checkout(git.Repo{Path: "/repo", Branch: "main"})).To(Succeed())
+		repoMgr.AssertNotCalled(GinkgoT(), "Checkout", mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "checkout", Args: []string{"branch main", "in", "/repo"}}))
+	})
+
+	It("should plan to unshallow repositories", func() {
+		Expect(mgr.Unshallow(git.Repo{Path: "/repo", Depth: 1})).To(Succeed())
+		repoMgr.AssertNotCalled(GinkgoT(), "Unshallow", mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "unshallow", Args: []string{"/repo"}}))
+	})
+
+	It("should not compare repositories that would be cloned with their pin", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+
+		Expect(mgr.Drifted(git.Repo{Path: "/repo", Tag: "v1.0"})).To(BeEmpty())
+		repoMgr.AssertNotCalled(GinkgoT(), "Drifted", mock.Anything)
+	})
+
+	It("should plan to init repositories that don't exist", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+
+		Expect(mgr.Init("/repo")).To(Succeed())
+		repoMgr.AssertNotCalled(GinkgoT(), "Init", mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "git init", Args: []string{"/repo"}}))
+	})
+
+	It("should plan to clone when cloning", func() {
+		repoMgr.On("Dump", "/repo").Return((*git.Repo)(nil), fmt.Errorf("fail"))
+
+		Expect(mgr.Clone("/origin", "/repo")).To(Succeed())
+		repoMgr.AssertNotCalled(GinkgoT(), "Clone", mock.Anything, mock.Anything)
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "clone", Args: []string{"/origin", "->", "/repo"}}))
+	})
+})
@@ -0,0 +1,108 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconf "gopkg.in/src-d/go-git.v4/config"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// DefaultRoot is the directory repositories added by their url are cloned
+// into, unless another is configured with repositoryRoot
+const DefaultRoot = "~/code"
+
+// ErrInvalidURL is returned when a checkout path can't be made from a url
+var ErrInvalidURL = errors.New("no host and path in url")
+
+// scpLike matches urls in the user@host:path form that ssh and git use
+var scpLike = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// IsURL checks if the given argument is the url of a remote repository
+// rather than a path.
+func IsURL(arg string) bool {
+	if strings.Contains(arg, "://") {
+		return true
+	}
+
+	return strings.Contains(arg, "@") && scpLike.MatchString(arg)
+}
+
+// CheckoutPath returns where the repository at the url is cloned to, as
+// host/owner/name below the root.
+func CheckoutPath(root, rawurl string) (string, error) {
+	var host, path string
+	if strings.Contains(rawurl, "://") {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to parse url %s", rawurl)
+		}
+
+		host, path = u.Hostname(), u.Path
+	} else if match := scpLike.FindStringSubmatch(rawurl); match != nil {
+		host, path = match[1], match[2]
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return "", errors.Wrapf(ErrInvalidURL, "unable to make a path for %s", rawurl)
+	}
+
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return "", errors.Wrapf(ErrInvalidURL, "unable to make a path for %s", rawurl)
+		}
+	}
+
+	return filepath.Join(root, host, filepath.FromSlash(path)), nil
+}
+
+// AddURL clones the repository at the url into its checkout path below the
+// configured root and adds it, so that it is cloned on the next ensure on
+// other machines too. The path it is cloned to is returned.
+func (mgr Manager) AddURL(rawurl string) (string, error) {
+	root := mgr.config.RepositoryRoot
+	if root == "" {
+		root = DefaultRoot
+	}
+
+	path, err := CheckoutPath(mgr.snapshot.ExpandHome(root), rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"url": rawurl, "path": path})
+	config := mgr.readConfig()
+	for _, repo := range config.Repositories {
+		if repo.Path == path {
+			logger.Info("Repository is already added")
+			printer.Log.Note("<fg 5>%s<reset> is already added", mgr.snapshot.UnexpandHome(path))
+			return path, nil
+		}
+	}
+
+	c := gitconf.NewConfig()
+	c.Remotes[git.DefaultRemoteName] = &gitconf.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{rawurl},
+		Fetch: []gitconf.RefSpec{gitconf.RefSpec(fmt.Sprintf(gitconf.DefaultFetchRefSpec, git.DefaultRemoteName))},
+	}
+	repo := Repo{Name: filepath.Base(path), Path: path, Config: c}
+
+	logger.Info("Cloning repository to add")
+	err = mgr.RepoManager.Ensure(repo)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to clone %s", rawurl)
+	}
+
+	printer.Log.Success("cloned <fg 5>%s<reset> into <fg 5>%s", rawurl, mgr.snapshot.UnexpandHome(path))
+	config.Repositories = append(config.Repositories, repo)
+	return path, mgr.saveConfig(config)
+}
@@ -1,21 +1,103 @@
 package git
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	billy "gopkg.in/src-d/go-billy.v4"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/run"
 )
 
 // RepoManager ...
 type RepoManager interface {
 	Dump(dir string) (*Repo, error)
 	Ensure(repo Repo) error
-	Update(dir string) (bool, error)
+	Update(repo Repo, dirty string) (*UpdateResult, error)
+	LocalChanges(dir string) (modified, untracked []string, err error)
+	Status(repo Repo) (*RepoStatus, error)
+	Checkout(repo Repo) error
+	Drifted(repo Repo) (string, error)
+	Unshallow(repo Repo) error
+	Init(dir string) error
+	Clone(url, dir string) error
+	Commit(dir, message string, include func(file string) bool) (bool, error)
+	Sync(dir string) error
+}
+
+var (
+	// ErrNoRemote is returned when syncing a repository without a remote
+	ErrNoRemote = errors.New("repository has no remote")
+	// ErrDiverged is returned when the repository and its remote both have
+	// commits the other doesn't that conflict, which has to be resolved
+	// manually
+	ErrDiverged = errors.New("repository has diverged from its remote")
+	// ErrUnknownDirtyStrategy is returned when updating a repository with
+	// local changes in a way that isn't one of the known ones
+	ErrUnknownDirtyStrategy = errors.New("unknown strategy for repositories with local changes")
+	// ErrShallowAhead is returned when updating a shallow repository with
+	// commits its remote doesn't have, which can't be merged
+	ErrShallowAhead = errors.New("shallow repository has commits its remote doesn't have")
+	// ErrAmbiguousCommit is returned when a repository is pinned to an
+	// abbreviated commit that more than one commit starts with
+	ErrAmbiguousCommit = errors.New("abbreviated commit is ambiguous")
+)
+
+// The ways a repository with local changes can be updated
+const (
+	// DirtySkip doesn't update the repository, it is the default
+	DirtySkip = "skip"
+	// DirtyStash stashes the changes and applies them again after pulling
+	DirtyStash = "stash"
+	// DirtyForce discards the changes and untracked files before pulling
+	DirtyForce = "force"
+)
+
+// UpdateResult describes what updating a repository did
+type UpdateResult struct {
+	// Updated is set if there was anything new to pull
+	Updated bool
+	// Skipped is set if the repository wasn't updated as it has local
+	// changes
+	Skipped bool
+	// Stashed is set if the local changes were stashed while updating
+	Stashed   bool
+	Modified  []string
+	Untracked []string
+	// Ahead and Behind count the commits the checked out branch and its
+	// remote counterpart have that the other doesn't
+	Ahead  int
+	Behind int
+}
+
+func (result UpdateResult) String() string {
+	return fmt.Sprintf("%d modified and %d untracked files, %d commits ahead and %d behind its remote",
+		len(result.Modified), len(result.Untracked), result.Ahead, result.Behind)
+}
+
+// RepoStatus describes the state of a repository on disk compared to its
+// remote.
+type RepoStatus struct {
+	Exists bool
+	Clean  bool
+	Behind int
+	// Drifted describes what is checked out if it isn't what the
+	// repository is pinned to
+	Drifted string
 }
 
 // GoGitRepoManager ...
@@ -83,6 +165,17 @@ func (mgr goGitRepoManager) Dump(dir string) (*Repo, error) {
 	}, nil
 }
 
+// origin returns the url the repository is cloned from
+func origin(repo Repo) (string, error) {
+	if repo.Config != nil {
+		if r, ok := repo.Config.Remotes[git.DefaultRemoteName]; ok && len(r.URLs) > 0 {
+			return r.URLs[0], nil
+		}
+	}
+
+	return "", errors.Errorf("no url to clone the repository from [path: %s]", repo.Path)
+}
+
 // Ensure ...
 func (mgr goGitRepoManager) Ensure(repo Repo) error {
 	logger := logrus.WithFields(logrus.Fields{
@@ -91,9 +184,14 @@ func (mgr goGitRepoManager) Ensure(repo Repo) error {
 	})
 	logger.Info("Ensuring repository exists")
 
-	if _, ok := mgr.open(repo.Path); ok == nil {
+	if repository, ok := mgr.open(repo.Path); ok == nil {
 		logger.Info("Repository already exists")
-		return nil
+		return updateSubmodules(repository, repo, false)
+	}
+
+	remote, err := origin(repo)
+	if err != nil {
+		return err
 	}
 
 	storage, worktree, err := mgr.storage(repo.Path)
@@ -101,29 +199,99 @@ func (mgr goGitRepoManager) Ensure(repo Repo) error {
 		return errors.Wrapf(err, "failed to get storage [path: %s]", repo.Path)
 	}
 
-	remote := repo.Config.Remotes[git.DefaultRemoteName].URLs[0]
+	options := &git.CloneOptions{URL: remote, Depth: repo.Depth, SingleBranch: repo.SingleBranch}
+	switch {
+	case repo.Commit != "":
+	case repo.Tag != "":
+		if repo.SingleBranch {
+			options.ReferenceName = plumbing.ReferenceName("refs/tags/" + repo.Tag)
+		}
+	case repo.Branch != "":
+		options.ReferenceName = branchName(repo.Branch)
+	}
 
-	logger = logger.WithField("remote", remote)
+	logger = logger.WithFields(logrus.Fields{"remote": remote, "pin": repo.Pin()})
 	logger.Debug("Cloning repository from remote")
-	repository, err := git.Clone(storage, worktree, &git.CloneOptions{
-		URL: remote,
-	})
+	repository, err := git.Clone(storage, worktree, options)
 	if err != nil {
 		return errors.Wrapf(err, "failed to clone repository [path: %s]", repo.Path)
 	}
 
-	err = repository.Storer.SetConfig(repo.Config)
+	err = mgr.checkout(repository, repo)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check out %s [path: %s]", repo.Pin(), repo.Path)
+	}
+
+	cloned, err := repository.Config()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get repository configuration [path: %s]", repo.Path)
+	}
+
+	// keep the origin as cloned, so that only what was cloned is fetched
+	// for a single branch, and the branch it set up to track it
+	apply(repo, cloned)
+	err = repository.Storer.SetConfig(cloned)
 	if err != nil {
 		return errors.Wrapf(err, "unable to set repository's configuration [path: %s]", repo.Path)
 	}
 
+	return updateSubmodules(repository, repo, true)
+}
+
+// Init creates an empty repository in the given directory, unless there
+// already is one.
+func (mgr goGitRepoManager) Init(dir string) error {
+	logger := logrus.WithField("repo", dir)
+	if _, err := mgr.open(dir); err == nil {
+		logger.Info("Repository already exists")
+		return nil
+	}
+
+	storage, worktree, err := mgr.storage(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get storage [path: %s]", dir)
+	}
+
+	logger.Info("Initialising repository")
+	_, err = git.Init(storage, worktree)
+	if err != nil {
+		return errors.Wrapf(err, "failed to initialise repository [path: %s]", dir)
+	}
+
+	return nil
+}
+
+// Clone clones the repository at the url into the given directory, unless
+// there already is a repository there.
+func (mgr goGitRepoManager) Clone(url, dir string) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"repo":   dir,
+		"remote": url,
+	})
+	if _, err := mgr.open(dir); err == nil {
+		logger.Info("Repository already exists")
+		return nil
+	}
+
+	storage, worktree, err := mgr.storage(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get storage [path: %s]", dir)
+	}
+
+	logger.Debug("Cloning repository from remote")
+	_, err = git.Clone(storage, worktree, &git.CloneOptions{URL: url})
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone repository [path: %s]", dir)
+	}
+
 	return nil
 }
 
-// Update ...
-func (mgr goGitRepoManager) Update(dir string) (bool, error) {
+// Commit stages the changed files that include accepts, given relative to
+// the repository, and commits them with the message. It returns false if
+// there was nothing to commit.
+func (mgr goGitRepoManager) Commit(dir, message string, include func(file string) bool) (bool, error) {
 	logger := logrus.WithField("repo", dir)
-	logger.Info("Updating repository")
 
 	repository, err := mgr.open(dir)
 	if err != nil {
@@ -135,17 +303,859 @@ func (mgr goGitRepoManager) Update(dir string) (bool, error) {
 		return false, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", dir)
 	}
 
-	updated := true
-	err = w.Pull(&git.PullOptions{RemoteName: git.DefaultRemoteName})
+	status, err := w.Status()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get status of repository [path: %s]", dir)
+	}
+
+	staged := 0
+	for file, s := range status {
+		switch {
+		case s.Worktree == git.Unmodified:
+			if s.Staging != git.Unmodified {
+				staged++
+			}
+			continue
+		case !include(file):
+			logger.WithField("file", file).Debug("Not staging excluded file")
+			continue
+		case s.Worktree == git.Deleted:
+			_, err = w.Remove(file)
+		default:
+			_, err = w.Add(file)
+		}
+
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to stage %s [path: %s]", file, dir)
+		}
+		staged++
+	}
+
+	if staged == 0 {
+		logger.Info("Nothing to commit")
+		return false, nil
+	}
+
+	author, err := mgr.author(repository)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = w.Commit(message, &git.CommitOptions{Author: author})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to commit [path: %s]", dir)
+	}
+
+	logger.WithField("files", staged).Info("Committed changes")
+	return true, nil
+}
+
+// author returns who commits are made by, the user configured for the
+// repository or otherwise the current user on this machine.
+func (mgr goGitRepoManager) author(repository *git.Repository) (*object.Signature, error) {
+	config, err := repository.Config()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get repository configuration")
+	}
+
+	user := config.Raw.Section("user")
+	name, email := user.Option("name"), user.Option("email")
+	if name == "" || email == "" {
+		facts, err := machine.New(nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to determine the commit author")
+		}
+
+		if name == "" {
+			name = facts.User
+		}
+		if email == "" {
+			email = facts.User + "@" + facts.Hostname
+		}
+	}
+
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// Sync fetches from the default remote and brings the repository and the
+// remote in sync, pulling when the repository is behind and pushing when it
+// is ahead. If both have commits the other lacks the local ones are rebased
+// onto the remote's, unless they conflict.
+func (mgr goGitRepoManager) Sync(dir string) error {
+	logger := logrus.WithField("repo", dir)
+
+	repository, err := mgr.open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open git repository [path: %s]", dir)
+	}
+
+	if _, err = repository.Remote(git.DefaultRemoteName); err != nil {
+		return errors.Wrapf(ErrNoRemote, "no %s remote [path: %s]", git.DefaultRemoteName, dir)
+	}
+
+	logger.Info("Fetching from remote")
+	err = repository.Fetch(&git.FetchOptions{RemoteName: git.DefaultRemoteName})
+	if err != nil && err != git.NoErrAlreadyUpToDate && err != transport.ErrEmptyRemoteRepository {
+		return errors.Wrapf(err, "failed to fetch [path: %s]", dir)
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get HEAD of repository [path: %s]", dir)
+	}
+
+	remoteName := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultRemoteName, head.Name().Short()))
+	ahead, behind, err := compare(repository, head.Hash(), remoteName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compare with remote [path: %s]", dir)
+	}
+
+	logger = logger.WithFields(logrus.Fields{"ahead": ahead, "behind": behind})
+	if ahead > 0 && behind > 0 {
+		logger.Info("Repository has diverged from remote, rebasing onto it")
+		err = mgr.rebase(dir, remoteName.Short())
+		if err != nil {
+			logger.WithError(err).Warn("Unable to rebase onto remote")
+			return errors.Wrapf(ErrDiverged, "%d commits ahead and %d behind, %s", ahead, behind, err)
+		}
+
+		behind = 0
+	}
+
+	if behind > 0 {
+		logger.Info("Pulling from remote")
+		w, err := repository.Worktree()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get worktree for repository [path: %s]", dir)
+		}
+
+		err = w.Pull(&git.PullOptions{RemoteName: git.DefaultRemoteName, ReferenceName: head.Name()})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return errors.Wrapf(err, "failed to pull [path: %s]", dir)
+		}
+	}
+
+	if ahead > 0 {
+		logger.Info("Pushing to remote")
+		err = repository.Push(&git.PushOptions{RemoteName: git.DefaultRemoteName})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return errors.Wrapf(err, "failed to push [path: %s]", dir)
+		}
+	}
+
+	return nil
+}
+
+// compare counts the commits head and the remote branch have that the
+// other doesn't. A branch the remote doesn't have yet is ahead.
+func compare(repository *git.Repository, head plumbing.Hash, remoteName plumbing.ReferenceName) (ahead, behind int, err error) {
+	remote, err := repository.Reference(remoteName, true)
+	if err != nil {
+		return 1, 0, nil
+	}
+
+	behind, err = countMissing(repository, remote.Hash(), head)
 	if err != nil {
-		if err == git.NoErrAlreadyUpToDate {
-			logger.Info("repository is already up to date")
-			updated = false
-		} else {
-			return false, errors.Wrapf(err, "failed to update repository [path: %s]", dir)
+		return 0, 0, err
+	}
+
+	ahead, err = countMissing(repository, head, remote.Hash())
+	return ahead, behind, err
+}
+
+// rebase rebases the checked out branch onto upstream with git, as go-git
+// can't, leaving the repository as it was if the commits conflict.
+func (mgr goGitRepoManager) rebase(dir, upstream string) error {
+	repo := Repo{Path: dir}
+	err := mgr.runGit(repo, "rebase", upstream)
+	if err == nil {
+		return nil
+	}
+
+	if abortErr := mgr.runGit(repo, "rebase", "--abort"); abortErr != nil {
+		return multierror.Append(err, abortErr)
+	}
+
+	return err
+}
+
+// Update pulls the branch the repository is pinned to, or what is checked
+// out if it isn't pinned. Repositories pinned to a tag or commit are only
+// fetched, they stay where they are until their pin is bumped. A repository
+// with local changes is skipped, unless dirty says to stash or discard them.
+func (mgr goGitRepoManager) Update(repo Repo, dirty string) (*UpdateResult, error) {
+	dir := repo.Path
+	logger := logrus.WithFields(logrus.Fields{"repo": dir, "pin": repo.Pin()})
+	logger.Info("Updating repository")
+
+	repository, err := mgr.open(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open git repository [path: %s]", dir)
+	}
+
+	result := &UpdateResult{}
+	if repo.Commit != "" || repo.Tag != "" {
+		logger.Info("Repository is pinned, only fetching")
+		return result, mgr.fetch(repository, repo)
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", dir)
+	}
+
+	result.Modified, result.Untracked, err = localChanges(w)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get status of repository [path: %s]", dir)
+	}
+
+	if len(result.Modified) > 0 || len(result.Untracked) > 0 {
+		logger = logger.WithField("dirty", dirty)
+		switch dirty {
+		case "", DirtySkip:
+			logger.Info("Repository has local changes, not updating")
+			err = mgr.fetch(repository, repo)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Skipped = true
+			result.Ahead, result.Behind = mgr.divergence(repository, repo)
+			return result, nil
+		case DirtyStash:
+			logger.Info("Stashing local changes")
+			err = mgr.runGit(repo, "stash", "push", "--include-untracked", "--message", "punkt update")
+			if err != nil {
+				return nil, err
+			}
+			result.Stashed = true
+		case DirtyForce:
+			logger.Info("Discarding local changes")
+			err = mgr.discard(repository, w, repo)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to discard local changes [path: %s]", dir)
+			}
+		default:
+			return nil, errors.Wrapf(ErrUnknownDirtyStrategy, "%s", dirty)
 		}
 	}
 
+	result.Updated, err = mgr.pull(repository, repo)
+	if result.Stashed {
+		logger.Info("Applying stashed changes")
+		if popErr := mgr.runGit(repo, "stash", "pop"); popErr != nil {
+			err = multierror.Append(err, errors.Wrap(popErr, "the local changes are kept in the stash"))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Info("Repository successfully updated")
-	return updated, nil
+	result.Ahead, result.Behind = mgr.divergence(repository, repo)
+	return result, updateSubmodules(repository, repo, true)
+}
+
+// pull checks out the branch the repository is pinned to and pulls it,
+// returning whether there was anything new.
+func (mgr goGitRepoManager) pull(repository *git.Repository, repo Repo) (bool, error) {
+	options := &git.PullOptions{RemoteName: git.DefaultRemoteName}
+	if repo.Branch != "" {
+		err := mgr.checkout(repository, repo)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check out %s [path: %s]", repo.Pin(), repo.Path)
+		}
+
+		options.ReferenceName = branchName(repo.Branch)
+	}
+
+	if repo.Depth > 0 {
+		return mgr.updateShallow(repository, repo)
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", repo.Path)
+	}
+
+	err = w.Pull(options)
+	if err == git.NoErrAlreadyUpToDate {
+		logrus.WithField("repo", repo.Path).Info("repository is already up to date")
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to update repository [path: %s]", repo.Path)
+	}
+
+	return true, nil
+}
+
+// LocalChanges returns the files of the repository that are modified,
+// staged or deleted and those that are untracked.
+func (mgr goGitRepoManager) LocalChanges(dir string) ([]string, []string, error) {
+	repository, err := mgr.open(dir)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to open git repository [path: %s]", dir)
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", dir)
+	}
+
+	modified, untracked, err := localChanges(w)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get status of repository [path: %s]", dir)
+	}
+
+	return modified, untracked, nil
+}
+
+// localChanges returns the files that are modified, staged or deleted and
+// those that are untracked, sorted.
+func localChanges(w *git.Worktree) (modified, untracked []string, err error) {
+	status, err := w.Status()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for file, s := range status {
+		switch {
+		case s.Worktree == git.Untracked:
+			untracked = append(untracked, file)
+		case s.Worktree != git.Unmodified || s.Staging != git.Unmodified:
+			modified = append(modified, file)
+		}
+	}
+
+	sort.Strings(modified)
+	sort.Strings(untracked)
+	return modified, untracked, nil
+}
+
+// discard resets the worktree to HEAD and removes the untracked files
+func (mgr goGitRepoManager) discard(repository *git.Repository, w *git.Worktree, repo Repo) error {
+	head, err := repository.Head()
+	if err != nil {
+		return err
+	}
+
+	err = w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset})
+	if err != nil {
+		return err
+	}
+
+	return mgr.runGit(repo, "clean", "--force", "-d")
+}
+
+// divergence counts the commits the checked out branch and its remote
+// counterpart have that the other doesn't. Nothing is counted if there is
+// no remote branch or the history can't be read.
+func (mgr goGitRepoManager) divergence(repository *git.Repository, repo Repo) (ahead, behind int) {
+	logger := logrus.WithField("repo", repo.Path)
+
+	head, err := repository.Head()
+	if err != nil || !head.Name().IsBranch() {
+		logger.Debug("HEAD isn't a branch, not comparing with remote")
+		return 0, 0
+	}
+
+	remoteName := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultRemoteName, head.Name().Short()))
+	remote, err := repository.Reference(remoteName, true)
+	if err != nil {
+		logger.WithError(err).Debug("branch has no remote counterpart, not comparing with remote")
+		return 0, 0
+	}
+
+	ahead, err = countMissing(repository, head.Hash(), remote.Hash())
+	if err == nil {
+		behind, err = countMissing(repository, remote.Hash(), head.Hash())
+	}
+	if err != nil {
+		logger.WithError(err).Debug("unable to compare with remote")
+		return 0, 0
+	}
+
+	return ahead, behind
+}
+
+// updateShallow fetches as shallow as the repository is and moves the
+// checked out branch to the remote's, as go-git can't pull into a shallow
+// repository. A branch with commits the remote doesn't have isn't moved.
+func (mgr goGitRepoManager) updateShallow(repository *git.Repository, repo Repo) (bool, error) {
+	logger := logrus.WithField("repo", repo.Path)
+
+	head, err := repository.Head()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get HEAD of repository [path: %s]", repo.Path)
+	}
+
+	if !head.Name().IsBranch() {
+		logger.Info("HEAD is detached, only fetching")
+		return false, mgr.fetch(repository, repo)
+	}
+
+	// the remote branch may already have been fetched past the branch
+	remoteName := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultRemoteName, head.Name().Short()))
+	before, err := repository.Reference(remoteName, true)
+	if err != nil || !contains(repository, before.Hash(), head.Hash()) {
+		return false, errors.Wrapf(ErrShallowAhead, "%s [path: %s]", head.Name().Short(), repo.Path)
+	}
+
+	err = mgr.fetch(repository, repo)
+	if err != nil {
+		return false, err
+	}
+
+	remote, err := repository.Reference(remoteName, true)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get %s [path: %s]", remoteName, repo.Path)
+	}
+
+	if remote.Hash() == head.Hash() {
+		logger.Info("repository is already up to date")
+		return false, nil
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", repo.Path)
+	}
+
+	err = w.Reset(&git.ResetOptions{Commit: remote.Hash(), Mode: git.MergeReset})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to update repository [path: %s]", repo.Path)
+	}
+
+	return true, nil
+}
+
+// Checkout checks out what the repository is pinned to, fetching from the
+// default remote if it isn't known yet.
+func (mgr goGitRepoManager) Checkout(repo Repo) error {
+	repository, err := mgr.open(repo.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open git repository [path: %s]", repo.Path)
+	}
+
+	err = mgr.checkout(repository, repo)
+	if err != nil {
+		return err
+	}
+
+	return updateSubmodules(repository, repo, true)
+}
+
+func (mgr goGitRepoManager) checkout(repository *git.Repository, repo Repo) error {
+	if repo.Pin() == "" {
+		return nil
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"repo": repo.Path, "pin": repo.Pin()})
+	if checkedOut, err := drifted(repository, repo); err == nil && checkedOut == "" {
+		logger.Debug("Repository already has its pin checked out")
+		return nil
+	}
+
+	options, err := checkoutOptions(repository, repo)
+	if err != nil {
+		logger.WithError(err).Debug("Pin not found, fetching from remote")
+		err = mgr.fetch(repository, repo)
+		if err != nil {
+			return err
+		}
+
+		options, err = checkoutOptions(repository, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get worktree for repository [path: %s]", repo.Path)
+	}
+
+	logger.Info("Checking out pin")
+	err = w.Checkout(options)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check out %s [path: %s]", repo.Pin(), repo.Path)
+	}
+
+	return nil
+}
+
+// Drifted describes what the repository has checked out if it isn't what it
+// is pinned to, it is empty if the repository isn't pinned.
+func (mgr goGitRepoManager) Drifted(repo Repo) (string, error) {
+	if repo.Pin() == "" {
+		return "", nil
+	}
+
+	repository, err := mgr.open(repo.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open git repository [path: %s]", repo.Path)
+	}
+
+	return drifted(repository, repo)
+}
+
+func drifted(repository *git.Repository, repo Repo) (string, error) {
+	head, err := repository.Head()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get HEAD of repository [path: %s]", repo.Path)
+	}
+
+	switch {
+	case repo.Commit != "":
+		hash, err := commitHash(repository, repo.Commit)
+		if err != nil {
+			return "", errors.Wrapf(err, "unknown commit %s [path: %s]", repo.Commit, repo.Path)
+		}
+
+		if head.Hash() == hash {
+			return "", nil
+		}
+	case repo.Tag != "":
+		hash, err := tagCommit(repository, repo.Tag)
+		if err != nil {
+			return "", errors.Wrapf(err, "unknown tag %s [path: %s]", repo.Tag, repo.Path)
+		}
+
+		if head.Hash() == hash {
+			return "", nil
+		}
+	case repo.Branch != "":
+		if head.Name() == branchName(repo.Branch) {
+			return "", nil
+		}
+	default:
+		return "", nil
+	}
+
+	if head.Name().IsBranch() {
+		return "branch " + head.Name().Short(), nil
+	}
+
+	return "commit " + head.Hash().String(), nil
+}
+
+// checkoutOptions returns how to check out what the repository is pinned
+// to, a pinned branch that doesn't exist yet is created from the remote's.
+func checkoutOptions(repository *git.Repository, repo Repo) (*git.CheckoutOptions, error) {
+	switch {
+	case repo.Commit != "":
+		hash, err := commitHash(repository, repo.Commit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown commit %s [path: %s]", repo.Commit, repo.Path)
+		}
+
+		return &git.CheckoutOptions{Hash: hash}, nil
+	case repo.Tag != "":
+		hash, err := tagCommit(repository, repo.Tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unknown tag %s [path: %s]", repo.Tag, repo.Path)
+		}
+
+		return &git.CheckoutOptions{Hash: hash}, nil
+	}
+
+	branch := branchName(repo.Branch)
+	if _, err := repository.Reference(branch, true); err == nil {
+		return &git.CheckoutOptions{Branch: branch}, nil
+	}
+
+	remoteName := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultRemoteName, repo.Branch))
+	remote, err := repository.Reference(remoteName, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown branch %s [path: %s]", repo.Branch, repo.Path)
+	}
+
+	return &git.CheckoutOptions{Branch: branch, Hash: remote.Hash(), Create: true}, nil
+}
+
+// commitHash returns the hash of the commit, which can be abbreviated as
+// long as only one commit starts with it.
+func commitHash(repository *git.Repository, commit string) (plumbing.Hash, error) {
+	if len(commit) == 40 {
+		hash := plumbing.NewHash(commit)
+		_, err := repository.CommitObject(hash)
+		return hash, err
+	}
+
+	commits, err := repository.CommitObjects()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var found []plumbing.Hash
+	prefix := strings.ToLower(commit)
+	err = commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), prefix) {
+			found = append(found, c.Hash)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch len(found) {
+	case 0:
+		return plumbing.ZeroHash, plumbing.ErrObjectNotFound
+	case 1:
+		return found[0], nil
+	}
+
+	return plumbing.ZeroHash, ErrAmbiguousCommit
+}
+
+// tagCommit returns the commit the tag points to, whether it is annotated
+// or not.
+func tagCommit(repository *git.Repository, name string) (plumbing.Hash, error) {
+	ref, err := repository.Reference(plumbing.ReferenceName("refs/tags/"+name), true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tag, err := repository.TagObject(ref.Hash())
+	if err != nil {
+		// a lightweight tag points directly to the commit
+		return ref.Hash(), nil
+	}
+
+	commit, err := tag.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit.Hash, nil
+}
+
+// updateSubmodules initialises the repository's submodules and checks out
+// the commits recorded for them, recursively. Unless all is set only those
+// that aren't initialised yet are updated. An error is returned for each
+// submodule that fails.
+func updateSubmodules(repository *git.Repository, repo Repo, all bool) error {
+	logger := logrus.WithField("repo", repo.Path)
+	if repo.SkipSubmodules {
+		logger.Debug("Skipping submodules")
+		return nil
+	}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get worktree for repository [path: %s]", repo.Path)
+	}
+
+	submodules, err := w.Submodules()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get submodules of repository [path: %s]", repo.Path)
+	}
+
+	var result error
+	for _, submodule := range submodules {
+		name := submodule.Config().Name
+		if !all {
+			status, err := submodule.Status()
+			if err == nil && !status.Current.IsZero() {
+				continue
+			}
+		}
+
+		logger.WithField("submodule", name).Info("Updating submodule")
+		err = submodule.Update(&git.SubmoduleUpdateOptions{
+			Init:              true,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		})
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "failed to update submodule %s [path: %s]", name, repo.Path))
+		}
+	}
+
+	return result
+}
+
+func branchName(branch string) plumbing.ReferenceName {
+	return plumbing.ReferenceName("refs/heads/" + branch)
+}
+
+// Status fetches the default remote and reports whether the repository
+// exists, has uncommitted changes, how many commits it is behind the remote
+// branch it tracks and whether it has drifted from its pin.
+func (mgr goGitRepoManager) Status(repo Repo) (*RepoStatus, error) {
+	logger := logrus.WithField("repo", repo.Path)
+	logger.Info("Checking repository status")
+
+	repository, err := mgr.open(repo.Path)
+	if err != nil {
+		logger.WithError(err).Debug("unable to open repository, assuming it doesn't exist")
+		return &RepoStatus{}, nil
+	}
+
+	status := &RepoStatus{Exists: true}
+
+	w, err := repository.Worktree()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get worktree for repository [path: %s]", repo.Path)
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get status of repository [path: %s]", repo.Path)
+	}
+	status.Clean = s.IsClean()
+
+	err = mgr.fetch(repository, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Drifted, err = drifted(repository, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get HEAD of repository [path: %s]", repo.Path)
+	}
+
+	if !head.Name().IsBranch() {
+		logger.Debug("HEAD is detached, not comparing with remote")
+		return status, nil
+	}
+
+	remoteName := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", git.DefaultRemoteName, head.Name().Short()))
+	remote, err := repository.Reference(remoteName, true)
+	if err != nil {
+		logger.WithError(err).Debug("branch has no remote counterpart, not comparing with remote")
+		return status, nil
+	}
+
+	status.Behind, err = countMissing(repository, remote.Hash(), head.Hash())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare with remote [path: %s]", repo.Path)
+	}
+
+	return status, nil
+}
+
+// Unshallow fetches the whole history of a shallow repository. It is done
+// with git as go-git can't deepen a shallow repository.
+func (mgr goGitRepoManager) Unshallow(repo Repo) error {
+	logger := logrus.WithField("repo", repo.Path)
+
+	repository, err := mgr.open(repo.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open git repository [path: %s]", repo.Path)
+	}
+
+	shallow, err := repository.Storer.Shallow()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the shallow commits of repository [path: %s]", repo.Path)
+	}
+
+	if len(shallow) == 0 {
+		logger.Info("Repository isn't shallow")
+		return nil
+	}
+
+	logger.Info("Fetching the whole history of repository")
+	return mgr.runGit(repo, "fetch", "--unshallow", git.DefaultRemoteName)
+}
+
+// fetch fetches from the default remote, keeping the repository as shallow
+// as it is configured to be. Shallow repositories are fetched with git as
+// go-git can't fetch into them.
+func (mgr goGitRepoManager) fetch(repository *git.Repository, repo Repo) error {
+	if repo.Depth > 0 {
+		return mgr.runGit(repo, "fetch", fmt.Sprintf("--depth=%d", repo.Depth), git.DefaultRemoteName)
+	}
+
+	err := repository.Fetch(&git.FetchOptions{RemoteName: git.DefaultRemoteName})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.Wrapf(err, "failed to fetch repository [path: %s]", repo.Path)
+	}
+
+	return nil
+}
+
+// runGit runs git in the repository, for what go-git can't do
+func (mgr goGitRepoManager) runGit(repo Repo, args ...string) error {
+	dir := filepath.Join(mgr.fs.Root(), repo.Path)
+	out, err := run.Commander("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "git %s failed: %s [path: %s]", strings.Join(args, " "), strings.TrimSpace(string(out)), repo.Path)
+	}
+
+	return nil
+}
+
+// contains returns true if the commit is reachable from tip. The search
+// ends where the history of a shallow repository is cut off.
+func contains(repository *git.Repository, tip, commit plumbing.Hash) bool {
+	if tip == commit {
+		return true
+	}
+
+	commits, err := repository.Log(&git.LogOptions{From: tip})
+	if err != nil {
+		return false
+	}
+
+	found := false
+	_ = commits.ForEach(func(c *object.Commit) error {
+		if c.Hash == commit {
+			found = true
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// countMissing counts the commits reachable from from that aren't reachable
+// from in. Only the history that has been fetched is counted in shallow
+// repositories.
+func countMissing(repository *git.Repository, from, in plumbing.Hash) (int, error) {
+	if from == in {
+		return 0, nil
+	}
+
+	reachable := make(map[plumbing.Hash]struct{})
+	commits, err := repository.Log(&git.LogOptions{From: in})
+	if err != nil {
+		return 0, err
+	}
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		reachable[c.Hash] = struct{}{}
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return 0, err
+	}
+
+	count := 0
+	commits, err = repository.Log(&git.LogOptions{From: from})
+	if err != nil {
+		return 0, err
+	}
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		if _, ok := reachable[c.Hash]; !ok {
+			count++
+		}
+
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return 0, err
+	}
+
+	return count, nil
 }
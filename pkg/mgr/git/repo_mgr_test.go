@@ -1,13 +1,17 @@
 package git_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	billy "gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/osfs"
@@ -15,11 +19,14 @@ import (
 	goGit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 
 	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/run"
 )
 
 func newStorage(fs billy.Filesystem, name string) (storage.Storer, billy.Filesystem) {
@@ -83,6 +90,28 @@ func addCommit(repo *goGit.Repository) plumbing.Hash {
 	return hash
 }
 
+// addSubmodule commits the repository at url as a submodule of repo at the
+// given commit.
+func addSubmodule(repo *goGit.Repository, worktree billy.Filesystem, name, url string, hash plumbing.Hash) {
+	f, err := worktree.OpenFile(".gitmodules", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	Expect(err).To(BeNil())
+	_, err = fmt.Fprintf(f, "[submodule \"%s\"]\n\tpath = %s\n\turl = %s\n", name, name, url)
+	Expect(err).To(BeNil())
+	Expect(f.Close()).To(Succeed())
+
+	w, err := repo.Worktree()
+	Expect(err).To(BeNil())
+	_, err = w.Add(".gitmodules")
+	Expect(err).To(BeNil())
+
+	idx, err := repo.Storer.Index()
+	Expect(err).To(BeNil())
+	idx.Entries = append(idx.Entries, &index.Entry{Name: name, Mode: filemode.Submodule, Hash: hash})
+	Expect(repo.Storer.SetIndex(idx)).To(Succeed())
+
+	addCommit(repo)
+}
+
 var _ = Describe("Git: Repo Manager", func() {
 	var fs billy.Filesystem
 	var tmpdir string
@@ -179,6 +208,24 @@ var _ = Describe("Git: Repo Manager", func() {
 			Expect(config.Core).To(Equal(repo.Config.Core))
 		})
 
+		It("should set the other remotes and git keys when cloning", func() {
+			origin, path := newRepository(fs, "origin", nil)
+			addCommit(origin)
+
+			Expect(mgr.Ensure(git.Repo{
+				Path:    "repo",
+				URL:     path.Root(),
+				Remotes: map[string]string{"upstream": "https://github.com/mbark/punkt"},
+				Git:     map[string]string{"user.email": "me@example.com"},
+			}.Expand())).To(Succeed())
+
+			config, err := openRepository(fs, "repo").Config()
+			Expect(err).To(BeNil())
+			Expect(config.Remotes).To(HaveKey(goGit.DefaultRemoteName))
+			Expect(config.Remotes["upstream"].URLs).To(Equal([]string{"https://github.com/mbark/punkt"}))
+			Expect(config.Raw.Section("user").Option("email")).To(Equal("me@example.com"))
+		})
+
 		It("should fail if the repository can't be cloned", func() {
 			name := "repo"
 			newRepository(fs, name, nil)
@@ -194,7 +241,634 @@ var _ = Describe("Git: Repo Manager", func() {
 		It("should fail if storage can't be allocated", func() {
 			Expect(mgr.Ensure(git.Repo{
 				Path: "../../",
-			})).NotTo(Succeed())
+				URL:  "https://github.com/mbark/punkt",
+			}.Expand())).NotTo(Succeed())
+		})
+
+		It("should fail naming the repository if it has no url to clone from", func() {
+			err := mgr.Ensure(git.Repo{Path: "/b"}.Expand())
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("/b"))
+
+			err = mgr.Ensure(git.Repo{
+				Path: "/a",
+				Git:  map[string]string{"user.email": "me@example.com"},
+			}.Expand())
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("/a"))
+		})
+	})
+
+	Context("Init", func() {
+		It("should create an empty repository", func() {
+			Expect(mgr.Init("repo")).To(Succeed())
+
+			repo, err := mgr.Dump("repo")
+			Expect(err).To(BeNil())
+			Expect(repo.Name).To(Equal("repo"))
+		})
+
+		It("should leave an existing repository as is", func() {
+			newRepository(fs, "repo", nil)
+
+			Expect(mgr.Init("repo")).To(Succeed())
+
+			repo, err := mgr.Dump("repo")
+			Expect(err).To(BeNil())
+			Expect(repo.Config.Remotes).To(HaveKey(goGit.DefaultRemoteName))
+		})
+	})
+
+	Context("Clone", func() {
+		It("should clone the repository", func() {
+			origin, path := newRepository(fs, "origin", nil)
+			hash := addCommit(origin)
+
+			Expect(mgr.Clone(path.Root(), "repo")).To(Succeed())
+
+			head, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			Expect(head.Hash()).To(Equal(hash))
+		})
+
+		It("should leave an existing repository as is", func() {
+			newRepository(fs, "repo", nil)
+
+			Expect(mgr.Clone("/does/not/exist", "repo")).To(Succeed())
+		})
+
+		It("should fail if the repository can't be cloned", func() {
+			Expect(mgr.Clone("/does/not/exist", "repo")).NotTo(Succeed())
+		})
+	})
+
+	Context("Commit", func() {
+		all := func(string) bool { return true }
+
+		BeforeEach(func() {
+			Expect(mgr.Init("repo")).To(Succeed())
+		})
+
+		It("should commit the changed files", func() {
+			Expect(util.WriteFile(fs, "repo/file", []byte("content"), 0644)).To(Succeed())
+
+			committed, err := mgr.Commit("repo", "punkt add", all)
+			Expect(err).To(BeNil())
+			Expect(committed).To(BeTrue())
+
+			head, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			commit, err := openRepository(fs, "repo").CommitObject(head.Hash())
+			Expect(err).To(BeNil())
+			Expect(commit.Message).To(Equal("punkt add"))
+
+			_, err = commit.File("file")
+			Expect(err).To(BeNil())
+		})
+
+		It("should not commit excluded files", func() {
+			Expect(util.WriteFile(fs, "repo/file", []byte("content"), 0644)).To(Succeed())
+			Expect(util.WriteFile(fs, "repo/excluded", []byte("content"), 0644)).To(Succeed())
+
+			committed, err := mgr.Commit("repo", "punkt add", func(file string) bool { return file != "excluded" })
+			Expect(err).To(BeNil())
+			Expect(committed).To(BeTrue())
+
+			w, err := openRepository(fs, "repo").Worktree()
+			Expect(err).To(BeNil())
+			status, err := w.Status()
+			Expect(err).To(BeNil())
+			Expect(status.File("excluded").Worktree).To(Equal(goGit.Untracked))
+		})
+
+		It("should do nothing if nothing has changed", func() {
+			committed, err := mgr.Commit("repo", "punkt add", all)
+			Expect(err).To(BeNil())
+			Expect(committed).To(BeFalse())
+		})
+
+		It("should fail if the directory isn't a repository", func() {
+			_, err := mgr.Commit("other", "punkt add", all)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("Sync", func() {
+		var origin string
+		all := func(string) bool { return true }
+
+		commit := func(dir, file string) {
+			Expect(util.WriteFile(fs, filepath.Join(dir, file), []byte(file), 0644)).To(Succeed())
+			committed, err := mgr.Commit(dir, "punkt add "+file, all)
+			Expect(err).To(BeNil())
+			Expect(committed).To(BeTrue())
+		}
+
+		BeforeEach(func() {
+			run.Commander = exec.Command
+			os.Setenv("GIT_COMMITTER_NAME", "John Doe")
+			os.Setenv("GIT_COMMITTER_EMAIL", "john@doe.org")
+
+			seed, worktree := newRepository(fs, "seed", nil)
+			addCommit(seed)
+
+			origin = filepath.Join(tmpdir, "origin")
+			_, err := goGit.PlainClone(origin, true, &goGit.CloneOptions{URL: worktree.Root()})
+			Expect(err).To(BeNil())
+
+			Expect(mgr.Clone(origin, "repo")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("GIT_COMMITTER_NAME")
+			os.Unsetenv("GIT_COMMITTER_EMAIL")
+		})
+
+		It("should push the local commits", func() {
+			commit("repo", "file")
+
+			Expect(mgr.Sync("repo")).To(Succeed())
+
+			head, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			remote, err := goGit.PlainOpen(origin)
+			Expect(err).To(BeNil())
+			ref, err := remote.Reference(head.Name(), true)
+			Expect(err).To(BeNil())
+			Expect(ref.Hash()).To(Equal(head.Hash()))
+		})
+
+		It("should pull the remote commits", func() {
+			Expect(mgr.Clone(origin, "other")).To(Succeed())
+			commit("other", "file")
+			Expect(mgr.Sync("other")).To(Succeed())
+
+			Expect(mgr.Sync("repo")).To(Succeed())
+			_, err := fs.Stat("repo/file")
+			Expect(err).To(BeNil())
+		})
+
+		It("should rebase the local commits onto the remote's when diverged", func() {
+			Expect(mgr.Clone(origin, "other")).To(Succeed())
+			commit("other", "other")
+			Expect(mgr.Sync("other")).To(Succeed())
+
+			commit("repo", "file")
+			Expect(mgr.Sync("repo")).To(Succeed())
+
+			Expect(mgr.Sync("other")).To(Succeed())
+			_, err := fs.Stat("other/file")
+			Expect(err).To(BeNil())
+			_, err = fs.Stat("repo/other")
+			Expect(err).To(BeNil())
+		})
+
+		It("should fail when the local commits conflict with the remote's", func() {
+			Expect(mgr.Clone(origin, "other")).To(Succeed())
+			commit("other", "file")
+			Expect(mgr.Sync("other")).To(Succeed())
+
+			Expect(util.WriteFile(fs, "repo/file", []byte("conflicting"), 0644)).To(Succeed())
+			_, err := mgr.Commit("repo", "punkt add file", all)
+			Expect(err).To(BeNil())
+
+			err = mgr.Sync("repo")
+			Expect(errors.Cause(err)).To(Equal(git.ErrDiverged))
+			_, err = fs.Stat("repo/.git/rebase-merge")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("should fail when the repository has no remote", func() {
+			Expect(mgr.Init("local")).To(Succeed())
+
+			err := mgr.Sync("local")
+			Expect(errors.Cause(err)).To(Equal(git.ErrNoRemote))
+		})
+	})
+
+	Context("Status", func() {
+		It("should report a missing repository", func() {
+			status, err := mgr.Status(git.Repo{Path: "missing"})
+			Expect(err).To(BeNil())
+			Expect(status.Exists).To(BeFalse())
+		})
+
+		It("should report how many commits the repository is behind", func() {
+			origin, path := newRepository(fs, "origin", nil)
+			addCommit(origin)
+			newRepository(fs, "repo", &config.RemoteConfig{
+				Name: goGit.DefaultRemoteName,
+				URLs: []string{path.Root()},
+			})
+
+			_, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
+			Expect(err).To(BeNil())
+			addCommit(origin)
+			addCommit(origin)
+
+			status, err := mgr.Status(git.Repo{Path: "repo"})
+			Expect(err).To(BeNil())
+			Expect(status.Exists).To(BeTrue())
+			Expect(status.Clean).To(BeTrue())
+			Expect(status.Behind).To(Equal(2))
+		})
+	})
+
+	Context("Pins", func() {
+		var origin *goGit.Repository
+		var first, second plumbing.Hash
+		var remote string
+
+		pinned := func(repo git.Repo) git.Repo {
+			c := config.NewConfig()
+			c.Remotes[goGit.DefaultRemoteName] = &config.RemoteConfig{
+				Name: goGit.DefaultRemoteName,
+				URLs: []string{remote},
+			}
+
+			repo.Path = "repo"
+			repo.Config = c
+			return repo
+		}
+
+		head := func() *plumbing.Reference {
+			ref, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			return ref
+		}
+
+		BeforeEach(func() {
+			var path billy.Filesystem
+			origin, path = newRepository(fs, "origin", nil)
+			first = addCommit(origin)
+			second = addCommit(origin)
+			remote = path.Root()
+
+			Expect(origin.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", first))).To(Succeed())
+			Expect(origin.Storer.SetReference(plumbing.NewHashReference("refs/tags/v1.0", first))).To(Succeed())
+		})
+
+		It("should clone the pinned branch", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{Branch: "dev"}))).To(Succeed())
+
+			Expect(head().Name()).To(Equal(plumbing.ReferenceName("refs/heads/dev")))
+			Expect(head().Hash()).To(Equal(first))
+		})
+
+		It("should check out the pinned tag when cloning", func() {
+			repo := pinned(git.Repo{Tag: "v1.0"})
+			Expect(mgr.Ensure(repo)).To(Succeed())
+
+			Expect(head().Hash()).To(Equal(first))
+			Expect(mgr.Drifted(repo)).To(BeEmpty())
+		})
+
+		It("should check out the pinned commit when cloning", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{Commit: first.String()}))).To(Succeed())
+
+			Expect(head().Hash()).To(Equal(first))
+		})
+
+		It("should check out the pinned commit given abbreviated", func() {
+			repo := pinned(git.Repo{Commit: first.String()[:7]})
+			Expect(mgr.Ensure(repo)).To(Succeed())
+
+			Expect(head().Hash()).To(Equal(first))
+			Expect(mgr.Drifted(repo)).To(BeEmpty())
+		})
+
+		It("should report what is checked out when it isn't the pin", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{}))).To(Succeed())
+
+			Expect(mgr.Drifted(pinned(git.Repo{Tag: "v1.0"}))).To(Equal("branch master"))
+			Expect(mgr.Drifted(pinned(git.Repo{Branch: "master"}))).To(BeEmpty())
+
+			status, err := mgr.Status(pinned(git.Repo{Commit: first.String()}))
+			Expect(err).To(BeNil())
+			Expect(status.Drifted).To(Equal("branch master"))
+		})
+
+		It("should not move repositories pinned to a tag when updating", func() {
+			repo := pinned(git.Repo{Tag: "v1.0"})
+			Expect(mgr.Ensure(repo)).To(Succeed())
+			addCommit(origin)
+
+			updated, err := mgr.Update(repo, git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(updated.Updated).To(BeFalse())
+			Expect(head().Hash()).To(Equal(first))
+		})
+
+		It("should switch to the pinned branch when updating", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{}))).To(Succeed())
+			Expect(head().Hash()).To(Equal(second))
+
+			_, err := mgr.Update(pinned(git.Repo{Branch: "dev"}), git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(head().Name()).To(Equal(plumbing.ReferenceName("refs/heads/dev")))
+			Expect(head().Hash()).To(Equal(first))
+		})
+
+		It("should fetch a pin that isn't known yet when checking it out", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{Tag: "v1.0"}))).To(Succeed())
+			Expect(origin.Storer.SetReference(plumbing.NewHashReference("refs/tags/v2.0", second))).To(Succeed())
+
+			Expect(mgr.Checkout(pinned(git.Repo{Tag: "v2.0"}))).To(Succeed())
+			Expect(head().Hash()).To(Equal(second))
+		})
+
+		It("should fail to check out a pin that doesn't exist", func() {
+			Expect(mgr.Ensure(pinned(git.Repo{}))).To(Succeed())
+
+			Expect(mgr.Checkout(pinned(git.Repo{Tag: "v3.0"}))).NotTo(Succeed())
+		})
+	})
+
+	Context("Submodules", func() {
+		var origin *goGit.Repository
+		var originPath billy.Filesystem
+		var lib plumbing.Hash
+		var libPath string
+
+		repo := func(skip bool) git.Repo {
+			c := config.NewConfig()
+			c.Remotes[goGit.DefaultRemoteName] = &config.RemoteConfig{
+				Name: goGit.DefaultRemoteName,
+				URLs: []string{originPath.Root()},
+			}
+
+			return git.Repo{Path: "repo", Config: c, SkipSubmodules: skip}
+		}
+
+		submodule := func(name string) *goGit.SubmoduleStatus {
+			w, err := openRepository(fs, "repo").Worktree()
+			Expect(err).To(BeNil())
+			sub, err := w.Submodule(name)
+			Expect(err).To(BeNil())
+			status, err := sub.Status()
+			Expect(err).To(BeNil())
+			return status
+		}
+
+		BeforeEach(func() {
+			r, path := newRepository(fs, "lib", nil)
+			lib = addCommit(r)
+			libPath = path.Root()
+
+			origin, originPath = newRepository(fs, "origin", nil)
+			addCommit(origin)
+		})
+
+		It("should initialise the submodules when cloning", func() {
+			addSubmodule(origin, originPath, "lib", libPath, lib)
+
+			Expect(mgr.Ensure(repo(false))).To(Succeed())
+			Expect(submodule("lib").Current).To(Equal(lib))
+		})
+
+		It("should leave the submodules of repositories opting out uninitialised", func() {
+			addSubmodule(origin, originPath, "lib", libPath, lib)
+
+			Expect(mgr.Ensure(repo(true))).To(Succeed())
+			Expect(submodule("lib").Current.IsZero()).To(BeTrue())
+		})
+
+		It("should initialise the submodules of existing repositories", func() {
+			addSubmodule(origin, originPath, "lib", libPath, lib)
+			Expect(mgr.Ensure(repo(true))).To(Succeed())
+
+			Expect(mgr.Ensure(repo(false))).To(Succeed())
+			Expect(submodule("lib").Current).To(Equal(lib))
+		})
+
+		It("should update the submodules after pulling", func() {
+			Expect(mgr.Ensure(repo(false))).To(Succeed())
+			addSubmodule(origin, originPath, "lib", libPath, lib)
+
+			updated, err := mgr.Update(repo(false), git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(updated.Updated).To(BeTrue())
+			Expect(submodule("lib").Current).To(Equal(lib))
+		})
+
+		It("should return an error for each submodule that fails", func() {
+			addSubmodule(origin, originPath, "first", "/does/not/exist", lib)
+			addSubmodule(origin, originPath, "second", "/does/not/exist", lib)
+
+			err := mgr.Ensure(repo(false))
+			Expect(err).NotTo(BeNil())
+			Expect(errors.Cause(err).(*multierror.Error).Errors).To(HaveLen(2))
+
+			_, err = fs.Stat("repo/.gitmodules")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("Shallow", func() {
+		var origin *goGit.Repository
+		var remote string
+
+		repo := func(r git.Repo) git.Repo {
+			c := config.NewConfig()
+			c.Remotes[goGit.DefaultRemoteName] = &config.RemoteConfig{
+				Name:  goGit.DefaultRemoteName,
+				URLs:  []string{remote},
+				Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+			}
+
+			r.Path = "repo"
+			r.Config = c
+			return r
+		}
+
+		shallow := func() []plumbing.Hash {
+			hashes, err := openRepository(fs, "repo").Storer.Shallow()
+			Expect(err).To(BeNil())
+			return hashes
+		}
+
+		BeforeEach(func() {
+			run.Commander = exec.Command
+			var path billy.Filesystem
+			origin, path = newRepository(fs, "origin", nil)
+			first := addCommit(origin)
+			addCommit(origin)
+			addCommit(origin)
+			remote = path.Root()
+
+			Expect(origin.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", first))).To(Succeed())
+		})
+
+		It("should clone only the given number of commits", func() {
+			Expect(mgr.Ensure(repo(git.Repo{Depth: 1}))).To(Succeed())
+			Expect(shallow()).NotTo(BeEmpty())
+		})
+
+		It("should stay shallow when updated", func() {
+			r := repo(git.Repo{Depth: 1})
+			Expect(mgr.Ensure(r)).To(Succeed())
+			addCommit(origin)
+
+			updated, err := mgr.Update(r, git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(updated.Updated).To(BeTrue())
+			Expect(shallow()).NotTo(BeEmpty())
+
+			head, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			originHead, err := origin.Head()
+			Expect(err).To(BeNil())
+			Expect(head.Hash()).To(Equal(originHead.Hash()))
+		})
+
+		It("should update a shallow branch its remote has already been fetched for", func() {
+			r := repo(git.Repo{Depth: 1})
+			Expect(mgr.Ensure(r)).To(Succeed())
+			addCommit(origin)
+
+			_, err := mgr.Status(r)
+			Expect(err).To(BeNil())
+
+			updated, err := mgr.Update(r, git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(updated.Updated).To(BeTrue())
+
+			head, err := openRepository(fs, "repo").Head()
+			Expect(err).To(BeNil())
+			originHead, err := origin.Head()
+			Expect(err).To(BeNil())
+			Expect(head.Hash()).To(Equal(originHead.Hash()))
+		})
+
+		It("should not move a shallow branch with commits of its own", func() {
+			r := repo(git.Repo{Depth: 1})
+			Expect(mgr.Ensure(r)).To(Succeed())
+			addCommit(openRepository(fs, "repo"))
+
+			_, err := mgr.Update(r, git.DirtySkip)
+			Expect(errors.Cause(err)).To(Equal(git.ErrShallowAhead))
+		})
+
+		It("should only fetch the cloned branch", func() {
+			r := repo(git.Repo{Branch: "master", SingleBranch: true})
+			Expect(mgr.Ensure(r)).To(Succeed())
+			_, err := mgr.Update(r, git.DirtySkip)
+			Expect(err).To(BeNil())
+
+			_, err = openRepository(fs, "repo").Reference("refs/remotes/origin/dev", true)
+			Expect(err).To(Equal(plumbing.ErrReferenceNotFound))
+			_, err = openRepository(fs, "repo").Reference("refs/remotes/origin/master", true)
+			Expect(err).To(BeNil())
+		})
+
+		It("should fetch the whole history when unshallowed", func() {
+			r := repo(git.Repo{Depth: 1})
+			Expect(mgr.Ensure(r)).To(Succeed())
+
+			Expect(mgr.Unshallow(r)).To(Succeed())
+			Expect(shallow()).To(BeEmpty())
+		})
+
+		It("should do nothing when unshallowing a complete repository", func() {
+			r := repo(git.Repo{})
+			Expect(mgr.Ensure(r)).To(Succeed())
+
+			Expect(mgr.Unshallow(r)).To(Succeed())
+		})
+	})
+
+	Context("Local changes", func() {
+		var origin *goGit.Repository
+		all := func(string) bool { return true }
+
+		read := func(file string) string {
+			f, err := fs.Open(file)
+			Expect(err).To(BeNil())
+			defer f.Close()
+
+			content, err := ioutil.ReadAll(f)
+			Expect(err).To(BeNil())
+			return string(content)
+		}
+
+		BeforeEach(func() {
+			run.Commander = exec.Command
+			os.Setenv("GIT_COMMITTER_NAME", "John Doe")
+			os.Setenv("GIT_COMMITTER_EMAIL", "john@doe.org")
+
+			var path billy.Filesystem
+			origin, path = newRepository(fs, "origin", nil)
+			Expect(util.WriteFile(fs, "origin/tracked", []byte("committed"), 0644)).To(Succeed())
+			_, err := mgr.Commit("origin", "add tracked", all)
+			Expect(err).To(BeNil())
+
+			Expect(mgr.Clone(path.Root(), "repo")).To(Succeed())
+			addCommit(origin)
+
+			Expect(util.WriteFile(fs, "repo/tracked", []byte("edited"), 0644)).To(Succeed())
+			Expect(util.WriteFile(fs, "repo/new", []byte("new"), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("GIT_COMMITTER_NAME")
+			os.Unsetenv("GIT_COMMITTER_EMAIL")
+		})
+
+		It("should skip the repository and report its changes", func() {
+			result, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
+			Expect(err).To(BeNil())
+			Expect(*result).To(Equal(git.UpdateResult{
+				Skipped:   true,
+				Modified:  []string{"tracked"},
+				Untracked: []string{"new"},
+				Behind:    1,
+			}))
+			Expect(read("repo/tracked")).To(Equal("edited"))
+		})
+
+		It("should list the changes without updating", func() {
+			modified, untracked, err := mgr.LocalChanges("repo")
+			Expect(err).To(BeNil())
+			Expect(modified).To(Equal([]string{"tracked"}))
+			Expect(untracked).To(Equal([]string{"new"}))
+		})
+
+		It("should skip the repository by default", func() {
+			result, err := mgr.Update(git.Repo{Path: "repo"}, "")
+			Expect(err).To(BeNil())
+			Expect(result.Skipped).To(BeTrue())
+		})
+
+		It("should keep the changes when stashing them", func() {
+			result, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtyStash)
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(BeTrue())
+			Expect(result.Stashed).To(BeTrue())
+			Expect(result.Behind).To(Equal(0))
+
+			Expect(read("repo/tracked")).To(Equal("edited"))
+			Expect(read("repo/new")).To(Equal("new"))
+		})
+
+		It("should discard the changes when forced", func() {
+			result, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtyForce)
+			Expect(err).To(BeNil())
+			Expect(result.Updated).To(BeTrue())
+
+			Expect(read("repo/tracked")).To(Equal("committed"))
+		})
+
+		It("should remove untracked files when forced", func() {
+			_, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtyForce)
+			Expect(err).To(BeNil())
+
+			_, err = fs.Stat("repo/new")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("should fail for an unknown strategy", func() {
+			_, err := mgr.Update(git.Repo{Path: "repo"}, "merge")
+			Expect(errors.Cause(err)).To(Equal(git.ErrUnknownDirtyStrategy))
 		})
 	})
 
@@ -216,9 +890,9 @@ var _ = Describe("Git: Repo Manager", func() {
 		It("should update the repository", func() {
 			hash := addCommit(origin)
 
-			updated, err := mgr.Update("repo")
+			updated, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
 			Expect(err).To(BeNil())
-			Expect(updated).To(BeTrue())
+			Expect(updated.Updated).To(BeTrue())
 
 			commit, err := repository.CommitObject(hash)
 			Expect(err).To(BeNil())
@@ -228,20 +902,20 @@ var _ = Describe("Git: Repo Manager", func() {
 		It("should succeed if the repository is already up to date", func() {
 			addCommit(origin)
 
-			_, err := mgr.Update("repo")
+			_, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
 			Expect(err).To(BeNil())
-			updated, err := mgr.Update("repo")
+			updated, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
 
 			Expect(err).To(BeNil())
-			Expect(updated).To(BeFalse())
+			Expect(updated.Updated).To(BeFalse())
 		})
 
 		It("should fail if the default remote doesn't exist", func() {
 			repository, _ = newRepository(fs, "noRemote", nil)
 
-			updated, err := mgr.Update("repo")
+			updated, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
 
-			Expect(updated).To(BeFalse())
+			Expect(updated).To(BeNil())
 			Expect(err).NotTo(BeNil())
 		})
 
@@ -249,13 +923,13 @@ var _ = Describe("Git: Repo Manager", func() {
 			err := util.RemoveAll(fs, "repo")
 			Expect(err).To(BeNil())
 
-			updated, err := mgr.Update("repo")
+			updated, err := mgr.Update(git.Repo{Path: "repo"}, git.DirtySkip)
 			Expect(err).NotTo(BeNil())
-			Expect(updated).To(BeFalse())
+			Expect(updated).To(BeNil())
 		})
 
 		It("should fail if the repository's storage can't be created", func() {
-			_, err := mgr.Update("../../")
+			_, err := mgr.Update(git.Repo{Path: "../../"}, git.DirtySkip)
 			Expect(err).NotTo(BeNil())
 		})
 	})
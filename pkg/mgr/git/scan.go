@@ -0,0 +1,137 @@
+package git
+
+import (
+	"path/filepath"
+	"sort"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	git "gopkg.in/src-d/go-git.v4"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// Scan walks dir looking for working copies with a remote, at most maxDepth
+// directories below it, and adds those that aren't configured yet. Neither
+// directories matching one of the ignore patterns, by name or path relative
+// to dir, nor working copies are walked into. The paths of the repositories
+// added are returned.
+func (mgr Manager) Scan(dir string, maxDepth int, ignore []string) ([]string, error) {
+	dir = mgr.absolute(dir)
+
+	for _, pattern := range ignore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid ignore pattern %s", pattern)
+		}
+	}
+
+	var result error
+	var found []string
+	mgr.walk(dir, dir, 0, maxDepth, ignore, &found, &result)
+
+	config := mgr.readConfig()
+	configured := make(map[string]bool)
+	for _, repo := range config.Repositories {
+		configured[repo.Path] = true
+	}
+
+	var added []string
+	for _, path := range found {
+		item := mgr.snapshot.UnexpandHome(path)
+		if configured[path] {
+			printer.Log.Note("<fg 5>%s<reset> is already added", item)
+			continue
+		}
+
+		repo, err := mgr.RepoManager.Dump(path)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "failed to dump repository at path: %s", path))
+			continue
+		}
+
+		if !hasRemote(*repo) {
+			printer.Log.Note("<fg 5>%s<reset> has no %s remote, skipping it", item, git.DefaultRemoteName)
+			continue
+		}
+
+		printer.Log.Success("added <fg 5>%s", item)
+		config.Repositories = append(config.Repositories, *repo)
+		configured[path] = true
+		added = append(added, path)
+	}
+
+	if len(added) == 0 {
+		return nil, result
+	}
+
+	err := mgr.saveConfig(config)
+	if err != nil {
+		return nil, multierror.Append(result, err)
+	}
+
+	return added, result
+}
+
+// walk adds the working copies in dir to found, the errors reading
+// directories are added to result.
+func (mgr Manager) walk(root, dir string, depth, maxDepth int, ignore []string, found *[]string, result *error) {
+	logger := logrus.WithField("dir", dir)
+	if _, err := mgr.snapshot.Fs.Stat(filepath.Join(dir, ".git")); err == nil {
+		logger.Debug("Found working copy")
+		*found = append(*found, dir)
+		return
+	}
+
+	if depth >= maxDepth {
+		return
+	}
+
+	infos, err := mgr.snapshot.Fs.ReadDir(dir)
+	if err != nil {
+		logger.WithError(err).Error("Unable to read directory")
+		*result = multierror.Append(*result, errors.Wrapf(err, "unable to read %s", dir))
+		return
+	}
+
+	// walked in order so working copies are added in the same order each time
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if !info.IsDir() || ignored(root, path, ignore) {
+			continue
+		}
+
+		mgr.walk(root, path, depth+1, maxDepth, ignore, found, result)
+	}
+}
+
+// ignored checks if the path matches one of the patterns, either by its
+// name or by its path relative to root.
+func ignored(root, path string, ignore []string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	for _, pattern := range ignore {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasRemote checks if the repository has an origin to be cloned from
+func hasRemote(repo Repo) bool {
+	if repo.Config == nil {
+		return false
+	}
+
+	remote, ok := repo.Config.Remotes[git.DefaultRemoteName]
+	return ok && len(remote.URLs) > 0
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	git "gopkg.in/src-d/go-git.v4"
+	gitconf "gopkg.in/src-d/go-git.v4/config"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+// ConfigKeys are the git config keys of a repository that are kept in
+// git.toml, in addition to those added to it by hand.
+var ConfigKeys = []string{
+	"user.name",
+	"user.email",
+	"user.signingkey",
+	"commit.gpgsign",
+	"core.autocrlf",
+	"core.filemode",
+	"pull.rebase",
+}
+
+// Compact replaces the repository's git configuration with its url,
+// remotes and git keys, which is how it's saved.
+func (repo Repo) Compact() Repo {
+	if repo.Config == nil {
+		return repo
+	}
+
+	c := repo.Config
+	keys := append([]string{}, ConfigKeys...)
+	for key := range repo.Git {
+		keys = append(keys, key)
+	}
+
+	repo.Config, repo.URL, repo.Remotes, repo.Git = nil, "", nil, nil
+	for name, remote := range c.Remotes {
+		if len(remote.URLs) == 0 {
+			continue
+		}
+
+		if name == git.DefaultRemoteName {
+			repo.URL = remote.URLs[0]
+			continue
+		}
+
+		if repo.Remotes == nil {
+			repo.Remotes = make(map[string]string)
+		}
+		repo.Remotes[name] = remote.URLs[0]
+	}
+
+	if c.Raw == nil {
+		return repo
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := option(c.Raw, key); value != "" {
+			if repo.Git == nil {
+				repo.Git = make(map[string]string)
+			}
+			repo.Git[key] = value
+		}
+	}
+
+	return repo
+}
+
+// Expand creates the repository's git configuration from its url, remotes
+// and git keys, it is left unset if the repository has none of them.
+func (repo Repo) Expand() Repo {
+	if repo.Config != nil || (repo.URL == "" && len(repo.Remotes) == 0 && len(repo.Git) == 0) {
+		return repo
+	}
+
+	c := gitconf.NewConfig()
+	remotes := map[string]string{}
+	for name, url := range repo.Remotes {
+		remotes[name] = url
+	}
+	if repo.URL != "" {
+		remotes[git.DefaultRemoteName] = repo.URL
+	}
+
+	for name, url := range remotes {
+		c.Remotes[name] = &gitconf.RemoteConfig{
+			Name:  name,
+			URLs:  []string{url},
+			Fetch: []gitconf.RefSpec{gitconf.RefSpec(fmt.Sprintf(gitconf.DefaultFetchRefSpec, name))},
+		}
+	}
+
+	for key, value := range repo.Git {
+		if i := strings.Index(key, "."); i > 0 {
+			c.Raw.Section(key[:i]).SetOption(key[i+1:], value)
+		}
+	}
+
+	repo.Config = c
+	return repo
+}
+
+// apply sets the repository's remotes, other than origin, and git keys in
+// the given configuration, keeping the rest of it.
+func apply(repo Repo, c *gitconf.Config) {
+	if repo.Config == nil {
+		return
+	}
+
+	for name, remote := range repo.Config.Remotes {
+		if name != git.DefaultRemoteName {
+			c.Remotes[name] = remote
+		}
+	}
+
+	keys := append([]string{}, ConfigKeys...)
+	for key := range repo.Git {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		value := option(repo.Config.Raw, key)
+		if i := strings.Index(key, "."); i > 0 && value != "" {
+			c.Raw.Section(key[:i]).SetOption(key[i+1:], value)
+		}
+	}
+}
+
+// option returns the value of the key, given as section.option, in the raw
+// configuration without adding the section if it's missing.
+func option(raw *format.Config, key string) string {
+	i := strings.Index(key, ".")
+	if raw == nil || i <= 0 {
+		return ""
+	}
+
+	for _, section := range raw.Sections {
+		if section.IsName(key[:i]) {
+			return section.Option(key[i+1:])
+		}
+	}
+
+	return ""
+}
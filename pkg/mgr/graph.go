@@ -0,0 +1,173 @@
+package mgr
+
+import (
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+var (
+	// ErrCycle is returned when managers are configured to run after each
+	// other in a cycle
+	ErrCycle = errors.New("managers are configured to run after each other in a cycle")
+	// ErrUnknownDependency is returned when a manager is configured to run
+	// after a manager that doesn't exist
+	ErrUnknownDependency = errors.New("manager is configured to run after an unknown manager")
+)
+
+// dependencies returns, for each of the managers, the indices of those among
+// them that it is configured to run after. Managers that exist but aren't
+// among the given ones are ignored.
+func (rootMgr RootManager) dependencies(mgrs []Manager) ([][]int, error) {
+	index := make(map[string]int)
+	for i := range mgrs {
+		index[mgrs[i].Name()] = i
+	}
+
+	after := make([][]int, len(mgrs))
+	for i := range mgrs {
+		for _, dep := range rootMgr.config.Dependencies[mgrs[i].Name()] {
+			if j, ok := index[dep]; ok {
+				after[i] = append(after[i], j)
+				continue
+			}
+
+			if _, ok := rootMgr.config.Managers[dep]; !ok && dep != "git" && dep != "symlink" {
+				return nil, errors.Wrapf(ErrUnknownDependency, "%s runs after %s", mgrs[i].Name(), dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(mgrs))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Wrapf(ErrCycle, "%s", strings.Join(append(path, mgrs[i].Name()), " -> "))
+		}
+
+		state[i] = visiting
+		path = append(path, mgrs[i].Name())
+		for _, j := range after[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+
+		return nil
+	}
+
+	for i := range mgrs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return after, nil
+}
+
+type outcome struct {
+	index int
+	err   error
+}
+
+// schedule calls run for each of the managers once those it runs after have
+// finished, with at most Jobs of them running at the same time. The number
+// of managers started so far is given to run for reporting progress. A
+// manager isn't run if one it runs after failed, which is reported as the
+// operation being skipped.
+func (rootMgr RootManager) schedule(mgrs []Manager, operation string, run func(mgr Manager, started int) error) error {
+	after, err := rootMgr.dependencies(mgrs)
+	if err != nil {
+		printer.Log.Error("unable to order the managers: <fg 1>%s", err)
+		return err
+	}
+
+	waiting := make([]int, len(mgrs))
+	dependents := make([][]int, len(mgrs))
+	var ready []int
+	for i := range mgrs {
+		waiting[i] = len(after[i])
+		for _, j := range after[i] {
+			dependents[j] = append(dependents[j], i)
+		}
+
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	jobs := rootMgr.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var result error
+	failed := make([]bool, len(mgrs))
+	finished, running, started := 0, 0, 0
+	complete := func(i int, err error) {
+		finished++
+		if err != nil {
+			failed[i] = true
+			result = multierror.Append(result, err)
+		}
+
+		for _, d := range dependents[i] {
+			failed[d] = failed[d] || failed[i]
+			waiting[d]--
+			if waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	outcomes := make(chan outcome)
+	for finished < len(mgrs) {
+		for running < jobs && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+
+			if failed[i] {
+				printer.Log.Warning("skipping <fg 3>%s<reset>, a manager it runs after failed", mgrs[i].Name())
+				printer.Log.Event(printer.Event{
+					Manager:   mgrs[i].Name(),
+					Operation: operation,
+					Result:    printer.ResultSkipped,
+					Message:   "a manager it runs after failed",
+				})
+				complete(i, nil)
+				continue
+			}
+
+			running++
+			go func(i, started int) {
+				outcomes <- outcome{index: i, err: run(mgrs[i], started)}
+			}(i, started)
+			started++
+		}
+
+		if running == 0 {
+			break
+		}
+
+		o := <-outcomes
+		running--
+		complete(o.index, o.err)
+	}
+
+	return result
+}
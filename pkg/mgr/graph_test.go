@@ -0,0 +1,172 @@
+package mgr_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/testmock"
+)
+
+// waitingManager updates by waiting for all managers in started to have
+// started, failing if they don't within a second.
+type waitingManager struct {
+	mockManager
+	name    string
+	started *sync.WaitGroup
+}
+
+func (m *waitingManager) Name() string {
+	return m.name
+}
+
+func (m *waitingManager) Update() error {
+	m.started.Done()
+	done := make(chan struct{})
+	go func() {
+		m.started.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Second):
+		return fmt.Errorf("%s was run alone", m.name)
+	}
+}
+
+var _ = Describe("Manager: Dependencies", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var root *mgr.RootManager
+	var order []string
+	var mutex sync.Mutex
+
+	named := func(name string, err error) *mockManager {
+		m := new(mockManager)
+		m.On("Name").Return(name)
+		m.On("Ensure").Run(func(mock.Arguments) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+		}).Return(err)
+		m.On("Update").Return(err)
+		return m
+	}
+
+	setup := func(dependencies map[string][]string) {
+		s, c := testmock.Setup()
+		snapshot, config = s, c
+		config.Managers = map[string]map[string]string{"a": {}, "b": {}, "c": {}}
+		config.Dependencies = dependencies
+		root = mgr.NewRootManager(config, snapshot)
+		root.LinkManager = new(testmock.LinkManager)
+	}
+
+	BeforeEach(func() {
+		order = nil
+		setup(nil)
+	})
+
+	It("should run managers after those they depend on", func() {
+		setup(map[string][]string{"a": {"b"}, "b": {"c"}})
+
+		Expect(root.Ensure([]mgr.Manager{named("a", nil), named("b", nil), named("c", nil)})).To(Succeed())
+		Expect(order).To(Equal([]string{"c", "b", "a"}))
+	})
+
+	It("should ignore dependencies on known managers that aren't run", func() {
+		setup(map[string][]string{"a": {"git", "b"}})
+
+		Expect(root.Ensure([]mgr.Manager{named("a", nil)})).To(Succeed())
+	})
+
+	It("should fail for dependencies on unknown managers", func() {
+		setup(map[string][]string{"a": {"unknown"}})
+		a := named("a", nil)
+
+		err := root.Ensure([]mgr.Manager{a})
+		Expect(errors.Cause(err)).To(Equal(mgr.ErrUnknownDependency))
+		a.AssertNotCalled(GinkgoT(), "Ensure")
+	})
+
+	It("should fail if the dependencies form a cycle", func() {
+		setup(map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}})
+		a := named("a", nil)
+
+		err := root.Update([]mgr.Manager{a, named("b", nil), named("c", nil)})
+		Expect(errors.Cause(err)).To(Equal(mgr.ErrCycle))
+		Expect(err.Error()).To(ContainSubstring("a -> b -> c -> a"))
+		a.AssertNotCalled(GinkgoT(), "Update")
+	})
+
+	It("should not run managers depending on one that failed", func() {
+		setup(map[string][]string{"a": {"b"}, "b": {"c"}})
+		a, b := named("a", nil), named("b", nil)
+
+		Expect(root.Ensure([]mgr.Manager{a, b, named("c", fmt.Errorf("fail"))})).NotTo(Succeed())
+		a.AssertNotCalled(GinkgoT(), "Ensure")
+		b.AssertNotCalled(GinkgoT(), "Ensure")
+	})
+
+	It("should still run managers not depending on one that failed", func() {
+		setup(map[string][]string{"a": {"b"}})
+
+		Expect(root.Ensure([]mgr.Manager{named("a", nil), named("b", fmt.Errorf("fail")), named("c", nil)})).NotTo(Succeed())
+		Expect(order).To(ConsistOf("b", "c"))
+	})
+
+	It("should run independent managers at the same time", func() {
+		root.Jobs = 2
+		var started sync.WaitGroup
+		started.Add(2)
+
+		Expect(root.Update([]mgr.Manager{
+			&waitingManager{name: "a", started: &started},
+			&waitingManager{name: "b", started: &started},
+		})).To(Succeed())
+	})
+
+	It("should keep the state of copies and templates ensured at the same time", func() {
+		root.Jobs = 3
+		root.LinkManager = symlink.NewLinkManager(config, snapshot)
+
+		files := map[string]string{
+			"/home/.dotfiles/a":      "a",
+			"/home/.dotfiles/b":      "b",
+			"/home/.dotfiles/c.tmpl": "c",
+			"/home/.dotfiles/d.tmpl": "d",
+			root.ConfigFile("a"): `[Symlinks]
+"/home/a" = { target = "/home/.dotfiles/a", copy = true }`,
+			root.ConfigFile("b"): `[Symlinks]
+"/home/b" = { target = "/home/.dotfiles/b", copy = true }`,
+			root.ConfigFile("symlink"): `"/home/c" = { template = "/home/.dotfiles/c.tmpl" }
+"/home/d" = { template = "/home/.dotfiles/d.tmpl" }`,
+		}
+		for file, content := range files {
+			Expect(snapshot.Save(content, file)).To(Succeed())
+		}
+
+		Expect(root.Ensure([]mgr.Manager{named("a", nil), named("b", nil), root.Symlink()})).To(Succeed())
+
+		copies := make(map[string]string)
+		Expect(snapshot.ReadToml(&copies, "/home/.config/punkt/copies.toml")).To(Succeed())
+		Expect(copies).To(HaveKey("~/a"))
+		Expect(copies).To(HaveKey("~/b"))
+
+		templates := make(map[string]interface{})
+		Expect(snapshot.ReadToml(&templates, "/home/.config/punkt/templates.toml")).To(Succeed())
+		Expect(templates).To(HaveKey("~/c"))
+		Expect(templates).To(HaveKey("~/d"))
+	})
+})
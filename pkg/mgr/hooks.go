@@ -0,0 +1,37 @@
+package mgr
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
+)
+
+// The hooks that can be configured for a manager in managers.toml, each is
+// a shell command run before or after the operation. A failing pre hook
+// stops the operation from being run.
+const (
+	hookPreEnsure  = "pre_ensure"
+	hookPostEnsure = "post_ensure"
+	hookPreUpdate  = "pre_update"
+	hookPostUpdate = "post_update"
+	hookPostDump   = "post_dump"
+)
+
+// hook runs the hook configured for the manager, if there is one
+func (rootMgr RootManager) hook(mgr Manager, hook string) error {
+	command := rootMgr.config.Managers[mgr.Name()][hook]
+	if command == "" {
+		return nil
+	}
+
+	printer.Log.Note("running %s hook for <fg 5>%s", hook, mgr.Name())
+
+	err := run.Hook(command, run.Prefix(mgr.Name(), rootMgr.Jobs > 1))
+	if err != nil {
+		printer.Log.Error("%s hook for <fg 2>%s<reset> failed with error <fg 1>%s", hook, mgr.Name(), err)
+		return errors.Wrapf(err, "%s hook failed for %s", hook, mgr.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package mgr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/prompt"
+)
+
+// candidates are the common dotfiles in the home directory that init offers
+// to adopt.
+var candidates = []string{
+	".bashrc",
+	".bash_profile",
+	".profile",
+	".zshrc",
+	".zprofile",
+	".vimrc",
+	".gitconfig",
+	".gitignore_global",
+	".tmux.conf",
+	".inputrc",
+	".editorconfig",
+	".config/nvim/init.vim",
+}
+
+const configTemplate = `# punkt configuration, flags given on the command line take precedence
+
+# dotfiles is the directory your dotfiles are stored in
+dotfiles = %q
+# punktHome is where punkt keeps its configuration and state
+punktHome = %q
+
+# conflict is how a file in the way of a symlink is handled, unless the
+# symlink has its own strategy: "skip", "backup", "overwrite" or "adopt".
+# Edited templates, secrets and copies are kept as they are when adopting.
+# conflict = "skip"
+
+# dirty is how git repositories with local changes are updated: "skip",
+# "stash" to stash the changes and apply them again after pulling, or
+# "force" to discard them
+# dirty = "skip"
+
+# repositoryRoot is where repositories added by their url are cloned, as
+# <host>/<owner>/<name> below it
+# repositoryRoot = "~/code"
+
+# logLevel is one of "debug", "info", "warn", "error" or "fatal"
+# logLevel = "info"
+
+# secretKeyFile contains the passphrase secrets are encrypted with, if it
+# isn't set the passphrase is asked for when needed
+# secretKeyFile = "~/.punkt.key"
+
+# variables can be used when rendering templates, as {{ .Variables.email }}
+[variables]
+# email = "me@example.com"
+`
+
+var scaffolds = map[string]string{
+	"managers": `# managers are the package managers to run. brew is built in and keeps what
+# is installed in a Brewfile in your dotfiles, as are apt, dnf and pacman
+# which keep the packages in <name>-packages.txt. Others are configured with
+# the command to use and optionally the commands for specific operations.
+# Each can be given the managers it has to run after and hooks, shell
+# commands run with pre_ensure, post_ensure, pre_update, post_update and
+# post_dump. git and symlink can be given hooks too, for example
+#
+# [brew]
+# after = ["git"]
+# post_ensure = "brew cleanup"
+#
+# [symlink]
+# post_ensure = "systemctl --user daemon-reload"
+#
+# [apt]
+# sudo = "sudo"
+#
+# [pip]
+# command = "pip"
+# dump = "pip freeze"
+# after = ["brew"]
+#
+# a manager can also be a plugin, an executable speaking punkt's plugin
+# protocol; executables named punkt-manager-<name> on the PATH are found
+# without being configured
+#
+# [nix]
+# plugin = "/usr/local/bin/punkt-manager-nix"
+`,
+	"symlink": `# symlinks are added with punkt add symlink, a symlink can be given a
+# shell command to run when ensure creates or changes it, for example
+#
+# "~/.local/share/fonts" = { target = "~/.dotfiles/fonts", on_change = "fc-cache" }
+`,
+	"git": `# repositories are added with punkt add repository, each is cloned from
+# its url and can have other remotes and git config keys of its own. A
+# repository can be pinned to a branch, tag or commit, which update bumps
+# with --bump, and its submodules are kept up to date unless
+# skip_submodules is set. Large repositories can be cloned shallow with
+# depth and single_branch, which update --unshallow undoes, e.g.
+#
+# [[Repositories]]
+# path = "~/.zsh/zsh-autosuggestions"
+# url = "https://github.com/zsh-users/zsh-autosuggestions"
+# tag = "v0.7.0"
+# skip_submodules = true
+# depth = 1
+# [Repositories.remotes]
+# fork = "git@github.com:me/zsh-autosuggestions.git"
+# [Repositories.git]
+# "user.email" = "me@example.com"
+`,
+}
+
+// Init scaffolds punkt's home directory, writing a commented configuration
+// to configFile along with the configuration files for managers, symlinks
+// and git, and makes the dotfiles directory a git repository. Files that
+// already exist are left as is. If adopt is set the user is offered to add
+// the common dotfiles found in their home directory.
+func (rootMgr RootManager) Init(configFile string, adopt bool) error {
+	printer.Log.Start("init", "punkt home: <fg 2>%s", rootMgr.snapshot.UnexpandHome(rootMgr.config.PunktHome))
+
+	var result error
+	content := fmt.Sprintf(configTemplate, rootMgr.config.Dotfiles, rootMgr.config.PunktHome)
+	err := rootMgr.scaffold(content, configFile)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	for _, name := range []string{"managers", "symlink", "git"} {
+		err = rootMgr.scaffold(scaffolds[name], rootMgr.ConfigFile(name))
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	err = rootMgr.Git().RepoManager.Init(rootMgr.config.Dotfiles)
+	if err != nil {
+		printer.Log.Error("failed to initialise the dotfiles repository: <fg 1>%s", err)
+		result = multierror.Append(result, errors.Wrapf(err, "unable to initialise %s", rootMgr.config.Dotfiles))
+	} else {
+		printer.Log.Success("dotfiles repository: <fg 2>%s", rootMgr.snapshot.UnexpandHome(rootMgr.config.Dotfiles))
+	}
+
+	if adopt && result == nil {
+		err = rootMgr.adopt()
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if result == nil {
+		printer.Log.Done("init", "init finished")
+	} else {
+		printer.Log.Error("init did not successfully complete")
+	}
+
+	return result
+}
+
+// scaffold saves the content to the file unless it already exists
+func (rootMgr RootManager) scaffold(content, file string) error {
+	item := rootMgr.snapshot.UnexpandHome(file)
+	if _, err := rootMgr.snapshot.Fs.Stat(file); err == nil {
+		printer.Log.Note("already exists: <fg 5>%s", item)
+		return nil
+	}
+
+	err := rootMgr.snapshot.Save(content, file)
+	if err != nil {
+		printer.Log.Error("failed to create <fg 5>%s<reset>: <fg 1>%s", item, err)
+		return errors.Wrapf(err, "unable to create %s", file)
+	}
+
+	printer.Log.Success("created: <fg 2>%s", item)
+	return nil
+}
+
+// adopt lets the user pick which of the candidates found in the home
+// directory to add to the dotfiles. Files that already are symlinks are
+// assumed to be managed and aren't offered.
+func (rootMgr RootManager) adopt() error {
+	var found []string
+	for _, candidate := range candidates {
+		fi, err := rootMgr.snapshot.Fs.Lstat(filepath.Join(rootMgr.snapshot.UserHome, candidate))
+		if err != nil || fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		found = append(found, "~/"+candidate)
+	}
+
+	if len(found) == 0 {
+		printer.Log.Note("found no dotfiles in your home directory to adopt")
+		return nil
+	}
+
+	var result error
+	mgr := rootMgr.Symlink()
+	for _, i := range prompt.Pick("found these dotfiles, which do you want to adopt?", found) {
+		_, err := mgr.Add(rootMgr.snapshot.ExpandHome(found[i]), "")
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "unable to adopt %s", found[i]))
+		}
+	}
+
+	return result
+}
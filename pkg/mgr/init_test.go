@@ -0,0 +1,109 @@
+package mgr_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/prompt"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Manager: Init", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var root *mgr.RootManager
+	var configFile string
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		root = mgr.NewRootManager(config, snapshot)
+		configFile = filepath.Join(config.PunktHome, "config.toml")
+
+		prompt.Out = new(bytes.Buffer)
+		prompt.In = strings.NewReader("\n")
+	})
+
+	It("should create a commented configuration", func() {
+		Expect(root.Init(configFile, false)).To(Succeed())
+
+		content, err := snapshot.Read(configFile)
+		Expect(err).To(BeNil())
+		Expect(content).To(ContainSubstring("# conflict"))
+
+		var saved map[string]interface{}
+		_, err = toml.Decode(content, &saved)
+		Expect(err).To(BeNil())
+		Expect(saved).To(HaveKeyWithValue("dotfiles", config.Dotfiles))
+		Expect(saved).To(HaveKeyWithValue("punktHome", config.PunktHome))
+	})
+
+	It("should create the manager configuration files", func() {
+		Expect(root.Init(configFile, false)).To(Succeed())
+
+		for _, name := range []string{"managers", "symlink", "git"} {
+			_, err := snapshot.Fs.Stat(root.ConfigFile(name))
+			Expect(err).To(BeNil())
+		}
+
+		_, err := root.Git().Dump()
+		Expect(err).To(BeNil())
+	})
+
+	It("should leave existing files as is", func() {
+		Expect(snapshot.Save("existing", configFile)).To(Succeed())
+
+		Expect(root.Init(configFile, false)).To(Succeed())
+		content, err := snapshot.Read(configFile)
+		Expect(err).To(BeNil())
+		Expect(content).To(Equal("existing"))
+	})
+
+	It("should make the dotfiles a git repository", func() {
+		Expect(root.Init(configFile, false)).To(Succeed())
+
+		_, err := git.NewRepoManager(snapshot.Fs).Dump(config.Dotfiles)
+		Expect(err).To(BeNil())
+	})
+
+	Context("adopting", func() {
+		BeforeEach(func() {
+			Expect(snapshot.Save("bash", filepath.Join(snapshot.UserHome, ".bashrc"))).To(Succeed())
+			Expect(snapshot.Save("vim", filepath.Join(snapshot.UserHome, ".vimrc"))).To(Succeed())
+		})
+
+		It("should adopt the picked dotfiles", func() {
+			prompt.In = strings.NewReader("2\n")
+
+			Expect(root.Init(configFile, true)).To(Succeed())
+
+			fi, err := snapshot.Fs.Lstat(filepath.Join(snapshot.UserHome, ".vimrc"))
+			Expect(err).To(BeNil())
+			Expect(fi.Mode() & os.ModeSymlink).NotTo(BeZero())
+
+			fi, err = snapshot.Fs.Lstat(filepath.Join(snapshot.UserHome, ".bashrc"))
+			Expect(err).To(BeNil())
+			Expect(fi.Mode() & os.ModeSymlink).To(BeZero())
+		})
+
+		It("should not offer files that already are symlinks", func() {
+			Expect(snapshot.Fs.Remove(filepath.Join(snapshot.UserHome, ".bashrc"))).To(Succeed())
+			Expect(snapshot.Fs.Symlink("/somewhere", filepath.Join(snapshot.UserHome, ".bashrc"))).To(Succeed())
+			out := new(bytes.Buffer)
+			prompt.Out = out
+
+			Expect(root.Init(configFile, true)).To(Succeed())
+			Expect(out.String()).NotTo(ContainSubstring(".bashrc"))
+			Expect(out.String()).To(ContainSubstring(".vimrc"))
+		})
+	})
+})
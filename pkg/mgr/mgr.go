@@ -3,16 +3,24 @@ package mgr
 import (
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/brew"
+	"github.com/mbark/punkt/pkg/mgr/distro"
 	"github.com/mbark/punkt/pkg/mgr/generic"
 	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/mgr/plugin"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/plan"
 	"github.com/mbark/punkt/pkg/printer"
 )
 
@@ -27,35 +35,124 @@ type Manager interface {
 	Dump() (string, error)
 	Ensure() error
 	Update() error
+	Status() ([]drift.Drift, error)
+}
+
+// Conditional is implemented by managers that are only run on the machines
+// matching their condition.
+type Conditional interface {
+	Condition() machine.Condition
+}
+
+// FileOwner is implemented by managers that dump to a file of their own,
+// nothing is saved in punkt's home for them.
+type FileOwner interface {
+	File() string
 }
 
 // RootManager ...
 type RootManager struct {
-	LinkManager symlink.LinkManager
-	snapshot    fs.Snapshot
-	config      conf.Config
+	LinkManager     symlink.LinkManager
+	TemplateManager symlink.TemplateManager
+	SecretManager   symlink.SecretManager
+	// Plan is set when doing a dry run, operations that can't be planned
+	// via the filesystem or run.Commander are then recorded in it.
+	Plan *plan.Plan
+	// Jobs is how many managers can be run at the same time
+	Jobs int
+	// links is held while symlinks are ensured, managers run at the same
+	// time share the files keeping the state of copies and templates
+	links *sync.Mutex
+	// plugins are those found on the PATH, they are only looked for once
+	plugins  *discovered
+	snapshot fs.Snapshot
+	config   conf.Config
+}
+
+type discovered struct {
+	once        sync.Once
+	executables map[string]string
 }
 
 // NewRootManager ...
 func NewRootManager(config conf.Config, snapshot fs.Snapshot) *RootManager {
 	return &RootManager{
-		LinkManager: symlink.NewLinkManager(config, snapshot),
-		snapshot:    snapshot,
-		config:      config,
+		LinkManager:     symlink.NewLinkManager(config, snapshot),
+		TemplateManager: symlink.NewTemplateManager(config, snapshot),
+		SecretManager:   symlink.NewSecretManager(config, snapshot),
+		Jobs:            1,
+		links:           new(sync.Mutex),
+		plugins:         new(discovered),
+		snapshot:        snapshot,
+		config:          config,
 	}
 }
 
-// All returns a list of all available managers
+// All returns a list of all available managers. Managers configured with
+// a plugin executable, or without a command but with a plugin on the PATH,
+// are run as plugins, unless they are one of the built-in ones. Plugins on
+// the PATH that aren't configured are included too if they respond as the
+// manager they are named as.
 func (rootMgr RootManager) All() []Manager {
+	plugins := rootMgr.discover()
+	delete(plugins, "git")
+	delete(plugins, "symlink")
+
 	var mgrs []Manager
-	for name := range rootMgr.config.Managers {
-		mgr := generic.NewManager(rootMgr.config, rootMgr.ConfigFile(name), name)
+	for name, settings := range rootMgr.config.Managers {
+		// git and symlink are only configured for what they run after and
+		// their hooks
+		if name == "git" || name == "symlink" {
+			continue
+		}
+
+		builtin := rootMgr.builtin(name)
+		executable, isPlugin := settings["plugin"]
+		if !isPlugin && settings["command"] == "" && builtin == nil {
+			executable, isPlugin = plugins[name]
+		}
+		delete(plugins, name)
+
+		switch {
+		case isPlugin:
+			mgrs = append(mgrs, rootMgr.plugin(name, executable))
+		case builtin != nil && settings["command"] == "":
+			mgrs = append(mgrs, builtin)
+		default:
+			mgr := generic.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile(name), name)
+			mgr.Prefixed = rootMgr.Jobs > 1
+			mgrs = append(mgrs, mgr)
+		}
+	}
+
+	for name, executable := range plugins {
+		mgr := rootMgr.plugin(name, executable)
+		if err := mgr.Verify(); err != nil {
+			printer.Log.Warning("ignoring plugin <fg 3>%s<reset>: %s", executable, err)
+			continue
+		}
+
 		mgrs = append(mgrs, mgr)
 	}
 
 	return append(mgrs, rootMgr.Git(), rootMgr.Symlink())
 }
 
+// discover returns the plugins on the PATH, which are looked for the first
+// time they are needed.
+func (rootMgr RootManager) discover() map[string]string {
+	rootMgr.plugins.once.Do(func() {
+		rootMgr.plugins.executables = plugin.Discover()
+	})
+
+	plugins := make(map[string]string)
+	for name, executable := range rootMgr.plugins.executables {
+		plugins[name] = executable
+	}
+
+	return plugins
+}
+
 func (rootMgr RootManager) names(mgrs []Manager) string {
 	var names []string
 	for i := range mgrs {
@@ -73,18 +170,11 @@ func (rootMgr RootManager) Dump(mgrs []Manager) error {
 	for i := range mgrs {
 		printer.Log.Progress(i, len(mgrs), "running dump for <fg 2>%s manager", mgrs[i].Name())
 
-		out, err := mgrs[i].Dump()
-		if err != nil {
-			printer.Log.Error("manager failed with error <fg 1>%s", err)
-			result = multierror.Append(result, errors.Wrapf(err, "dump failed for %s", mgrs[i].Name()))
-			continue
-		}
-
-		err = rootMgr.snapshot.Save(out, rootMgr.ConfigFile(mgrs[i].Name()))
+		started := time.Now()
+		err := rootMgr.dump(mgrs[i])
+		printer.Log.Event(printer.NewEvent(mgrs[i].Name(), "dump", "", started, err))
 		if err != nil {
-			printer.Log.Error("failed to save configuration with error <fg 1>%s", err)
-			result = multierror.Append(result, errors.Wrapf(err, "failed to save %s configuration", mgrs[i].Name()))
-			continue
+			result = multierror.Append(result, err)
 		}
 	}
 
@@ -92,41 +182,34 @@ func (rootMgr RootManager) Dump(mgrs []Manager) error {
 	return result
 }
 
-// Ensure ...
-func (rootMgr RootManager) Ensure(mgrs []Manager) error {
-	printer.Log.Start("ensure", "managers: <fg 2>%s", rootMgr.names(mgrs))
-
-	var result error
-	for i := range mgrs {
-		printer.Log.Progress(i, len(mgrs), "running ensure for <fg 2>%s manager", mgrs[i].Name())
-
-		logger := logrus.WithField("manager", mgrs[i].Name())
-		logger.Debug("running ensure")
+func (rootMgr RootManager) dump(mgr Manager) error {
+	out, err := mgr.Dump()
+	if err != nil {
+		printer.Log.Error("<fg 2>%s<reset> manager failed with error <fg 1>%s", mgr.Name(), err)
+		return errors.Wrapf(err, "dump failed for %s", mgr.Name())
+	}
 
-		err := mgrs[i].Ensure()
+	if _, ok := mgr.(FileOwner); !ok {
+		err = rootMgr.snapshot.Save(out, rootMgr.ConfigFile(mgr.Name()))
 		if err != nil {
-			printer.Log.Error("manager failed with error <fg 1>%s", err)
-			result = multierror.Append(result, errors.Wrapf(err, "ensure failed for %s", mgrs[i].Name()))
-			continue
+			printer.Log.Error("failed to save the configuration of <fg 2>%s<reset> with error <fg 1>%s", mgr.Name(), err)
+			return errors.Wrapf(err, "failed to save %s configuration", mgr.Name())
 		}
+	}
 
-		config, err := rootMgr.readSymlinks(mgrs[i].Name())
-		if err != nil {
-			printer.Log.Error("failed to read stored symlinks with error <fg 1>%s", err)
-			result = multierror.Append(result, errors.Wrapf(err, "unable to get %s configured symlinks", mgrs[i].Name()))
-			continue
-		}
+	return rootMgr.hook(mgr, hookPostDump)
+}
 
-		for i := range config.Symlinks {
-			expanded := rootMgr.LinkManager.Expand(config.Symlinks[i])
-			err = rootMgr.LinkManager.Ensure(expanded)
-			if err != nil {
-				printer.Log.Error("failed to create symlinks with error <fg 1>%s", err)
-				result = multierror.Append(result, errors.Wrapf(err, "unable to ensure %s for manager %s", config.Symlinks[i], mgrs[i].Name()))
-				continue
-			}
-		}
-	}
+// Ensure runs ensure for the managers and ensures the symlinks stored for
+// each of them. Managers are run once those they are configured to run after
+// have finished, independent ones at the same time.
+func (rootMgr RootManager) Ensure(mgrs []Manager) error {
+	printer.Log.Start("ensure", "managers: <fg 2>%s", rootMgr.names(mgrs))
+
+	result := rootMgr.schedule(mgrs, "ensure", func(mgr Manager, started int) error {
+		printer.Log.Progress(started, len(mgrs), "running ensure for <fg 2>%s manager", mgr.Name())
+		return rootMgr.report(mgr, "ensure", rootMgr.ensure)
+	})
 
 	if result == nil {
 		printer.Log.Done("ensure", "ensure finished")
@@ -137,29 +220,181 @@ func (rootMgr RootManager) Ensure(mgrs []Manager) error {
 	return result
 }
 
-// Update ...
+// report runs the operation for the manager if its condition applies,
+// emitting an event with the result.
+func (rootMgr RootManager) report(mgr Manager, operation string, run func(mgr Manager) error) error {
+	started := time.Now()
+	ok, err := rootMgr.applies(mgr)
+	if err != nil {
+		printer.Log.Error("failed to check the condition of <fg 2>%s<reset> with error <fg 1>%s", mgr.Name(), err)
+		err = errors.Wrapf(err, "unable to check the condition of %s", mgr.Name())
+	} else if !ok {
+		printer.Log.Event(printer.Event{
+			Manager:   mgr.Name(),
+			Operation: operation,
+			Result:    printer.ResultSkipped,
+			Message:   "the condition doesn't match the machine",
+		})
+		return nil
+	} else {
+		err = run(mgr)
+	}
+
+	printer.Log.Event(printer.NewEvent(mgr.Name(), operation, "", started, err))
+	return err
+}
+
+func (rootMgr RootManager) ensure(mgr Manager) error {
+	err := rootMgr.hook(mgr, hookPreEnsure)
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.WithField("manager", mgr.Name())
+	logger.Debug("running ensure")
+
+	err = mgr.Ensure()
+	if err != nil {
+		printer.Log.Error("<fg 2>%s<reset> manager failed with error <fg 1>%s", mgr.Name(), err)
+		return errors.Wrapf(err, "ensure failed for %s", mgr.Name())
+	}
+
+	err = rootMgr.ensureSymlinks(mgr)
+	if err != nil {
+		return err
+	}
+
+	return rootMgr.hook(mgr, hookPostEnsure)
+}
+
+// ensureSymlinks ensures the symlinks, templates and secrets stored for the
+// manager
+func (rootMgr RootManager) ensureSymlinks(mgr Manager) error {
+	rootMgr.links.Lock()
+	defer rootMgr.links.Unlock()
+
+	config, err := rootMgr.readSymlinks(mgr.Name())
+	if err != nil {
+		printer.Log.Error("failed to read the symlinks stored for <fg 2>%s<reset> with error <fg 1>%s", mgr.Name(), err)
+		return errors.Wrapf(err, "unable to get %s configured symlinks", mgr.Name())
+	}
+
+	err = rootMgr.stored().EnsureConfig(*config)
+	return errors.Wrapf(err, "unable to ensure the symlinks of %s", mgr.Name())
+}
+
+// Update runs update for the managers, ordered and run at the same time the
+// same way as for Ensure.
 func (rootMgr RootManager) Update(mgrs []Manager) error {
 	printer.Log.Start("update", "managers: <fg 2>%s", rootMgr.names(mgrs))
 
+	result := rootMgr.schedule(mgrs, "update", func(mgr Manager, started int) error {
+		printer.Log.Progress(started, len(mgrs), "<fg 2>%s", mgr.Name())
+		return rootMgr.report(mgr, "update", rootMgr.update)
+	})
+
+	if result == nil {
+		printer.Log.Done("update", "update finished")
+	} else {
+		printer.Log.Error("update did not successfully complete for all managers")
+	}
+
+	return result
+}
+
+func (rootMgr RootManager) update(mgr Manager) error {
+	err := rootMgr.hook(mgr, hookPreUpdate)
+	if err != nil {
+		return err
+	}
+
+	err = mgr.Update()
+	if err != nil {
+		printer.Log.Error("<fg 2>%s<reset> manager failed with error <fg 1>%s", mgr.Name(), err)
+		return errors.Wrapf(err, "update failed for %s", mgr.Name())
+	}
+
+	return rootMgr.hook(mgr, hookPostUpdate)
+}
+
+// Status goes through the managers and reports everything that differs
+// from what is configured, including the symlinks stored for each manager.
+func (rootMgr RootManager) Status(mgrs []Manager) ([]drift.Drift, error) {
+	printer.Log.Start("status", "managers: <fg 2>%s", rootMgr.names(mgrs))
+
 	var result error
+	var drifts []drift.Drift
 	for i := range mgrs {
-		printer.Log.Progress(i, len(mgrs), "<fg 2>%s", mgrs[i].Name())
+		printer.Log.Progress(i, len(mgrs), "checking status for <fg 2>%s manager", mgrs[i].Name())
 
-		err := mgrs[i].Update()
+		err := rootMgr.report(mgrs[i], "status", func(mgr Manager) error {
+			found, err := rootMgr.status(mgr)
+			drifts = append(drifts, found...)
+			return err
+		})
 		if err != nil {
-			printer.Log.Error("manager failed with error <fg 1>%s", err)
-			result = multierror.Append(result, errors.Wrapf(err, "update failed for %s", mgrs[i].Name()))
-			continue
+			result = multierror.Append(result, err)
 		}
 	}
 
-	if result == nil {
-		printer.Log.Done("update", "update finished")
+	if result != nil {
+		printer.Log.Error("status could not be determined for all managers")
+	} else if len(drifts) > 0 {
+		printer.Log.Warning("found <fg 3>%d<reset> differences from your dotfiles", len(drifts))
 	} else {
-		printer.Log.Error("update did not successfully complete for all managers")
+		printer.Log.Done("status", "everything is up to date")
 	}
 
-	return result
+	return drifts, result
+}
+
+// status returns the drift for the manager along with that of its stored
+// symlinks, templates and secrets, each of which is also reported as an
+// event.
+func (rootMgr RootManager) status(mgr Manager) ([]drift.Drift, error) {
+	var result error
+	found, err := mgr.Status()
+	if err != nil {
+		printer.Log.Error("<fg 2>%s<reset> manager failed with error <fg 1>%s", mgr.Name(), err)
+		result = multierror.Append(result, errors.Wrapf(err, "status failed for %s", mgr.Name()))
+	}
+
+	config, err := rootMgr.readSymlinks(mgr.Name())
+	if err != nil {
+		printer.Log.Error("failed to read the symlinks stored for <fg 2>%s<reset> with error <fg 1>%s", mgr.Name(), err)
+		result = multierror.Append(result, errors.Wrapf(err, "unable to get %s configured symlinks", mgr.Name()))
+	} else {
+		drifts, err := rootMgr.stored().StatusConfig(*config)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "unable to get the status of the symlinks of %s", mgr.Name()))
+		}
+
+		found = append(found, drifts...)
+	}
+
+	for _, d := range found {
+		printer.Log.Warning("<fg 2>%s<reset> <fg 3>%s<reset>: %s", mgr.Name(), d.Item, d.Reason)
+		printer.Log.Event(printer.Event{
+			Manager:   mgr.Name(),
+			Operation: "status",
+			Item:      d.Item,
+			Result:    printer.ResultDrift,
+			Message:   d.Reason,
+		})
+	}
+
+	return found, result
+}
+
+// applies checks if the manager's condition, if it has one, matches the
+// machine.
+func (rootMgr RootManager) applies(mgr Manager) (bool, error) {
+	conditional, ok := mgr.(Conditional)
+	if !ok {
+		return true, nil
+	}
+
+	return conditional.Condition().Applies(mgr.Name() + " manager")
 }
 
 func (rootMgr RootManager) readSymlinks(name string) (*symlink.Config, error) {
@@ -178,12 +413,53 @@ func (rootMgr RootManager) readSymlinks(name string) (*symlink.Config, error) {
 
 // Git ...
 func (rootMgr RootManager) Git() git.Manager {
-	return *git.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile("git"))
+	mgr := git.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile("git"))
+	if rootMgr.Plan != nil {
+		mgr.RepoManager = git.NewPlannedRepoManager(rootMgr.Plan, mgr.RepoManager)
+	}
+
+	return *mgr
+}
+
+// builtin returns the built-in manager with the name, or nil if there is
+// none
+func (rootMgr RootManager) builtin(name string) Manager {
+	if name == brew.Name {
+		mgr := brew.NewManager(rootMgr.config, rootMgr.snapshot)
+		mgr.Prefixed = rootMgr.Jobs > 1
+		return *mgr
+	}
+
+	if _, ok := distro.Tools[name]; ok {
+		mgr := distro.NewManager(rootMgr.config, rootMgr.snapshot, name)
+		mgr.Prefixed = rootMgr.Jobs > 1
+		return *mgr
+	}
+
+	return nil
+}
+
+func (rootMgr RootManager) plugin(name, executable string) plugin.Manager {
+	mgr := plugin.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile(name), name, executable)
+	mgr.Prefixed = rootMgr.Jobs > 1
+	return *mgr
 }
 
 // Symlink ...
 func (rootMgr RootManager) Symlink() symlink.Manager {
-	return *symlink.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile("symlink"))
+	mgr := symlink.NewManager(rootMgr.config, rootMgr.snapshot, rootMgr.ConfigFile("symlink"))
+	mgr.TemplateManager = rootMgr.TemplateManager
+	mgr.SecretManager = rootMgr.SecretManager
+	mgr.Mutex = rootMgr.links
+	return *mgr
+}
+
+// stored returns the symlink manager used for what is stored under the
+// symlinks of the other managers.
+func (rootMgr RootManager) stored() symlink.Manager {
+	mgr := rootMgr.Symlink()
+	mgr.LinkManager = rootMgr.LinkManager
+	return mgr
 }
 
 // ConfigFile ...
@@ -2,7 +2,13 @@ package mgr_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -11,9 +17,17 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/mgr/brew"
+	"github.com/mbark/punkt/pkg/mgr/distro"
+	"github.com/mbark/punkt/pkg/mgr/plugin"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
 	"github.com/mbark/punkt/testmock"
 )
 
@@ -41,6 +55,29 @@ func (m *mockManager) Update() error {
 	return args.Error(0)
 }
 
+func (m *mockManager) Status() ([]drift.Drift, error) {
+	args := m.Called()
+	drifts, _ := args.Get(0).([]drift.Drift)
+	return drifts, args.Error(1)
+}
+
+type conditionalManager struct {
+	mockManager
+	condition machine.Condition
+}
+
+func (m *conditionalManager) Condition() machine.Condition {
+	return m.condition
+}
+
+type fileManager struct {
+	mockManager
+}
+
+func (m *fileManager) File() string {
+	return "/home/.dotfiles/Brewfile"
+}
+
 func TestMgr(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Mgr Suite")
@@ -86,6 +123,81 @@ var _ = Describe("Manager", func() {
 
 			Expect(len(all)).To(Equal(3))
 		})
+
+		It("should not make managers of git and symlink when configured", func() {
+			config.Managers["git"] = map[string]string{}
+			config.Managers["symlink"] = map[string]string{}
+			root := mgr.NewRootManager(config, snapshot)
+
+			var names []string
+			for _, m := range root.All() {
+				names = append(names, m.Name())
+			}
+			Expect(names).To(ConsistOf(name, "git", "symlink"))
+		})
+
+		It("should run a manager configured with a plugin as a plugin", func() {
+			config.Managers[name]["plugin"] = "/usr/local/bin/punkt-manager-foo"
+			all := root.All()
+
+			Expect(all).To(ContainElement(BeAssignableToTypeOf(plugin.Manager{})))
+		})
+
+		It("should only look for plugins on the PATH once", func() {
+			lookups := 0
+			machine.LookupEnv = func(key string) (string, bool) {
+				if key == "PATH" {
+					lookups++
+				}
+				return "", false
+			}
+			defer func() { machine.LookupEnv = os.LookupEnv }()
+
+			root.All()
+			root.All()
+			Expect(lookups).To(Equal(1))
+		})
+
+		It("should verify the plugins on the PATH when doing a dry run", func() {
+			dir, err := ioutil.TempDir("", "punkt")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			Expect(ioutil.WriteFile(filepath.Join(dir, plugin.Prefix+"bar"), nil, 0755)).To(Succeed())
+
+			machine.LookupEnv = func(key string) (string, bool) { return dir, key == "PATH" }
+			defer func() { machine.LookupEnv = os.LookupEnv }()
+
+			readOnly, commander := run.ReadOnly, run.Commander
+			defer func() { run.ReadOnly, run.Commander = readOnly, commander }()
+			run.ReadOnly = testmock.FakeCommand("TestMgrHelperProcess")
+			run.Commander = func(string, ...string) *exec.Cmd {
+				Fail("the plugin should only be verified")
+				return nil
+			}
+
+			root.Plan = plan.New()
+			var names []string
+			for _, m := range root.All() {
+				names = append(names, m.Name())
+			}
+			Expect(names).To(ConsistOf(name, "git", "symlink"))
+		})
+
+		It("should use the built-in brew manager if brew has no command", func() {
+			config.Managers["brew"] = make(map[string]string)
+			all := root.All()
+
+			Expect(all).To(ContainElement(BeAssignableToTypeOf(brew.Manager{})))
+		})
+
+		It("should use the built-in distribution managers if they have no command", func() {
+			config.Managers["apt"] = make(map[string]string)
+			config.Managers["pacman"] = map[string]string{"command": "pacman"}
+			all := root.All()
+
+			Expect(all).To(ContainElement(BeAssignableToTypeOf(distro.Manager{})))
+			Expect(all).To(HaveLen(5))
+		})
 	})
 
 	Context("Dump", func() {
@@ -119,6 +231,17 @@ var _ = Describe("Manager", func() {
 
 			Expect(actual).To(Equal(expected))
 		})
+
+		It("should not save anything for managers dumping to their own file", func() {
+			owner := new(fileManager)
+			owner.On("Name").Return(name)
+			owner.On("Dump").Return("", nil)
+
+			Expect(root.Dump([]mgr.Manager{owner})).To(Succeed())
+
+			_, err := snapshot.Fs.Stat(root.ConfigFile(name))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
 	})
 
 	Context("Ensure", func() {
@@ -134,6 +257,16 @@ var _ = Describe("Manager", func() {
 			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
 		})
 
+		It("should name the manager that failed", func() {
+			out := new(bytes.Buffer)
+			printer.Log.Out = out
+			defer func() { printer.Log.Out = ioutil.Discard }()
+			mockMgr.On("Ensure").Return(fmt.Errorf("fail"))
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
+			Expect(out.String()).To(ContainSubstring(name + " manager failed"))
+		})
+
 		It("should ensure the symlink exists for the managers", func() {
 			mockMgr.On("Ensure").Return(nil)
 			linkMgr.On("Ensure", mock.Anything).Return(nil)
@@ -172,6 +305,24 @@ var _ = Describe("Manager", func() {
 			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
 		})
 
+		It("should render the templates stored for the managers", func() {
+			mockMgr.On("Ensure").Return(nil)
+			machine.Hostname = func() (string, error) { return "laptop", nil }
+			source := filepath.Join(config.Dotfiles, "gitconfig.tmpl")
+			Expect(snapshot.Save("{{ .Hostname }}", source)).To(Succeed())
+
+			stored := `[Symlinks]
+  "/home/.gitconfig" = { template = "` + source + `" }
+`
+			Expect(snapshot.Save(stored, root.ConfigFile(name))).To(Succeed())
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).To(Succeed())
+
+			content, err := snapshot.Read("/home/.gitconfig")
+			Expect(err).To(BeNil())
+			Expect(content).To(Equal("laptop"))
+		})
+
 		It("should fail if some config file can't be parsed", func() {
 			mockMgr.On("Ensure").Return(nil)
 			linkMgr.On("Ensure", mock.Anything).Return(nil)
@@ -182,6 +333,15 @@ var _ = Describe("Manager", func() {
 			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
 		})
 
+		It("should skip managers whose condition doesn't match", func() {
+			machine.Hostname = func() (string, error) { return "laptop", nil }
+			conditional := &conditionalManager{condition: machine.Condition{Host: "server-*"}}
+			conditional.On("Name").Return("bar")
+
+			Expect(root.Ensure([]mgr.Manager{conditional})).To(Succeed())
+			conditional.AssertNotCalled(GinkgoT(), "Ensure")
+		})
+
 		It("should succeed even if the toml file doesn't contain a symlinks key", func() {
 			mockMgr.On("Ensure").Return(nil)
 			linkMgr.On("Ensure", mock.Anything).Return(nil)
@@ -193,6 +353,54 @@ var _ = Describe("Manager", func() {
 		})
 	})
 
+	Context("Status", func() {
+		It("should report no drift if nothing differs", func() {
+			mockMgr.On("Status").Return(nil, nil)
+
+			drifts, err := root.Status([]mgr.Manager{mockMgr})
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should fail if a manager fails", func() {
+			mockMgr.On("Status").Return(nil, fmt.Errorf("fail"))
+
+			_, err := root.Status([]mgr.Manager{mockMgr})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should report the drift of the managers and their symlinks", func() {
+			managerDrift := drift.New("foo", "differs")
+			linkDrift := drift.New("/link", "symlink is missing")
+			mockMgr.On("Status").Return([]drift.Drift{managerDrift}, nil)
+			linkMgr.On("Status", mock.Anything).Return(&linkDrift)
+
+			mgrConfig := mgr.ManagerConfig{Symlinks: symlink.Config{
+				Symlinks: []symlink.Symlink{{Link: "/link", Target: "/target"}},
+			}}
+
+			err := snapshot.SaveToml(mgrConfig, root.ConfigFile(name))
+			Expect(err).To(BeNil())
+
+			drifts, err := root.Status([]mgr.Manager{mockMgr})
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(managerDrift, linkDrift))
+		})
+
+		It("should report the drift of the templates stored for the managers", func() {
+			mockMgr.On("Status").Return(nil, nil)
+			stored := `[Symlinks]
+  "/home/.gitconfig" = { template = "/home/.dotfiles/gitconfig.tmpl" }
+`
+			Expect(snapshot.Save(stored, root.ConfigFile(name))).To(Succeed())
+
+			drifts, err := root.Status([]mgr.Manager{mockMgr})
+			Expect(err).To(BeNil())
+			Expect(drifts).To(HaveLen(1))
+			Expect(drifts[0].Item).To(Equal("~/.gitconfig"))
+		})
+	})
+
 	Context("Update", func() {
 		It("should succeed if all managers do", func() {
 			mockMgr.On("Update").Return(nil)
@@ -205,5 +413,151 @@ var _ = Describe("Manager", func() {
 
 			Expect(root.Update([]mgr.Manager{mockMgr})).NotTo(Succeed())
 		})
+
+		It("should skip managers whose condition doesn't match", func() {
+			machine.Hostname = func() (string, error) { return "laptop", nil }
+			conditional := &conditionalManager{condition: machine.Condition{Host: "server-*"}}
+			conditional.On("Name").Return("bar")
+
+			Expect(root.Update([]mgr.Manager{conditional})).To(Succeed())
+			conditional.AssertNotCalled(GinkgoT(), "Update")
+		})
+	})
+
+	Context("Events", func() {
+		var events *bytes.Buffer
+
+		BeforeEach(func() {
+			events = new(bytes.Buffer)
+			Expect(printer.Log.SetFormat(printer.FormatJSONL)).To(Succeed())
+			printer.Log.Out = ioutil.Discard
+			printer.Log.EventsOut = events
+		})
+
+		AfterEach(func() {
+			Expect(printer.Log.SetFormat(printer.FormatText)).To(Succeed())
+		})
+
+		read := func() []printer.Event {
+			var found []printer.Event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var event printer.Event
+				Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+				event.Duration = 0
+				found = append(found, event)
+			}
+
+			return found
+		}
+
+		It("should emit the result of ensure for each manager", func() {
+			mockMgr.On("Ensure").Return(fmt.Errorf("fail"))
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
+			Expect(read()).To(Equal([]printer.Event{{
+				Manager:   name,
+				Operation: "ensure",
+				Result:    printer.ResultError,
+				Error:     "ensure failed for foo: fail",
+			}}))
+		})
+
+		It("should emit the managers skipped because of their condition", func() {
+			conditional := &conditionalManager{condition: machine.Condition{OS: "plan9"}}
+			conditional.On("Name").Return(name)
+
+			Expect(root.Update([]mgr.Manager{conditional})).To(Succeed())
+			Expect(read()).To(Equal([]printer.Event{{
+				Manager:   name,
+				Operation: "update",
+				Result:    printer.ResultSkipped,
+				Message:   "the condition doesn't match the machine",
+			}}))
+		})
+
+		It("should emit the drift found by status", func() {
+			mockMgr.On("Status").Return([]drift.Drift{drift.New("bar", "differs")}, nil)
+
+			_, err := root.Status([]mgr.Manager{mockMgr})
+			Expect(err).To(BeNil())
+			Expect(read()).To(Equal([]printer.Event{
+				{Manager: name, Operation: "status", Item: "bar", Result: printer.ResultDrift, Message: "differs"},
+				{Manager: name, Operation: "status", Result: printer.ResultSuccess},
+			}))
+		})
+	})
+
+	Context("Hooks", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = new(bytes.Buffer)
+			run.Out = out
+			run.Commander = testmock.FakeCommand("TestMgrHelperProcess")
+		})
+
+		hooks := func(hooks map[string]string) {
+			config.Managers[name] = hooks
+			root = mgr.NewRootManager(config, snapshot)
+			root.LinkManager = linkMgr
+		}
+
+		It("should run the hooks before and after ensure", func() {
+			hooks(map[string]string{"pre_ensure": "pre", "post_ensure": "post"})
+			mockMgr.On("Ensure").Return(nil)
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).To(Succeed())
+			Expect(out.String()).To(Equal("sh -c pre\nsh -c post\n"))
+		})
+
+		It("should not ensure if the pre hook fails", func() {
+			hooks(map[string]string{"pre_ensure": "pre"})
+			run.Commander = testmock.FakeWithEnvCommand("TestMgrHelperProcess", "FAILING=true")
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
+			mockMgr.AssertNotCalled(GinkgoT(), "Ensure")
+		})
+
+		It("should not run the post hook if ensure fails", func() {
+			hooks(map[string]string{"post_ensure": "post"})
+			mockMgr.On("Ensure").Return(fmt.Errorf("fail"))
+
+			Expect(root.Ensure([]mgr.Manager{mockMgr})).NotTo(Succeed())
+			Expect(out.String()).To(BeEmpty())
+		})
+
+		It("should run the hooks before and after update", func() {
+			hooks(map[string]string{"pre_update": "pre", "post_update": "post"})
+			mockMgr.On("Update").Return(nil)
+
+			Expect(root.Update([]mgr.Manager{mockMgr})).To(Succeed())
+			Expect(out.String()).To(Equal("sh -c pre\nsh -c post\n"))
+		})
+
+		It("should run the hook after dump", func() {
+			hooks(map[string]string{"post_dump": "post"})
+			mockMgr.On("Dump").Return("", nil)
+
+			Expect(root.Dump([]mgr.Manager{mockMgr})).To(Succeed())
+			Expect(out.String()).To(Equal("sh -c post\n"))
+		})
+
+		It("should fail if the post hook fails", func() {
+			hooks(map[string]string{"post_dump": "post"})
+			mockMgr.On("Dump").Return("", nil)
+			run.Commander = testmock.FakeWithEnvCommand("TestMgrHelperProcess", "FAILING=true")
+
+			Expect(root.Dump([]mgr.Manager{mockMgr})).NotTo(Succeed())
+		})
 	})
 })
+
+func TestMgrHelperProcess(t *testing.T) {
+	cmd, args, err := testmock.VerifyHelperProcess()
+	if err != nil {
+		return
+	}
+
+	fmt.Println(strings.Join(append([]string{cmd}, args...), " "))
+	os.Exit(0)
+}
@@ -0,0 +1,285 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
+)
+
+// Prefix is what the executables of plugins found on the PATH are named
+// with, followed by the name of the manager.
+const Prefix = "punkt-manager-"
+
+// The operations a plugin is run with
+const (
+	OpName   = "name"
+	OpDump   = "dump"
+	OpEnsure = "ensure"
+	OpUpdate = "update"
+	OpStatus = "status"
+)
+
+// Request is written as JSON to the plugin's stdin, the operation is also
+// given as its only argument.
+type Request struct {
+	Operation  string            `json:"operation"`
+	ConfigFile string            `json:"configFile"`
+	Dotfiles   string            `json:"dotfiles"`
+	PunktHome  string            `json:"punktHome"`
+	Settings   map[string]string `json:"settings"`
+}
+
+// Response is what the plugin writes as JSON to its stdout, nothing is the
+// same as an empty response. Anything the plugin wants to show the user
+// should be written to stderr.
+type Response struct {
+	// Name is the name of the manager, returned for the name operation
+	Name string `json:"name,omitempty"`
+	// Output is the configuration returned by dump, it is stored in the
+	// manager's configuration file
+	Output string `json:"output,omitempty"`
+	// Drift is what status found to differ from the configuration
+	Drift []Drift `json:"drift,omitempty"`
+	// Symlinks are ensured after ensure and checked by status
+	Symlinks []Symlink `json:"symlinks,omitempty"`
+	// Error is set if the operation failed
+	Error string `json:"error,omitempty"`
+}
+
+// Drift is something the plugin found to differ from the configuration
+type Drift struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// Symlink is a symlink the plugin wants punkt to manage
+type Symlink struct {
+	Link   string `json:"link"`
+	Target string `json:"target"`
+}
+
+// Manager runs an external executable speaking the plugin protocol
+type Manager struct {
+	LinkManager symlink.LinkManager
+	// Prefixed is set when managers run at the same time, what the plugin
+	// writes to stderr is then prefixed with the manager's name.
+	Prefixed   bool
+	name       string
+	executable string
+	config     conf.Config
+	snapshot   fs.Snapshot
+	configFile string
+}
+
+// NewManager creates a manager running the executable, name is what the
+// manager is configured as in managers.toml.
+func NewManager(c conf.Config, snapshot fs.Snapshot, configFile, name, executable string) *Manager {
+	logrus.WithFields(logrus.Fields{
+		"name":       name,
+		"executable": executable,
+	}).Info("Constructing plugin manager")
+
+	return &Manager{
+		LinkManager: symlink.NewLinkManager(c, snapshot),
+		name:        name,
+		executable:  executable,
+		config:      c,
+		snapshot:    snapshot,
+		configFile:  configFile,
+	}
+}
+
+// Discover finds the plugins on the PATH, returning the executable for
+// each name. If several have the same name the first on the PATH is used.
+func Discover() map[string]string {
+	found := make(map[string]string)
+	path, _ := machine.LookupEnv("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			logrus.WithField("dir", dir).WithError(err).Debug("unable to read directory on PATH")
+			continue
+		}
+
+		for _, f := range files {
+			name := strings.TrimPrefix(f.Name(), Prefix)
+			if name == f.Name() || name == "" || f.IsDir() || f.Mode()&0111 == 0 {
+				continue
+			}
+
+			if _, ok := found[name]; !ok {
+				found[name] = filepath.Join(dir, f.Name())
+			}
+		}
+	}
+
+	logrus.WithField("plugins", found).Debug("discovered plugins")
+	return found
+}
+
+// Name ...
+func (mgr Manager) Name() string {
+	return mgr.name
+}
+
+// Condition returns the host, os, arch and env condition configured for the
+// manager in managers.toml.
+func (mgr Manager) Condition() machine.Condition {
+	return machine.ConditionFrom(mgr.config.Managers[mgr.name])
+}
+
+// Verify runs the name operation to check that the executable speaks the
+// plugin protocol and is the manager it is configured as.
+func (mgr Manager) Verify() error {
+	resp, err := mgr.call(OpName)
+	if err != nil {
+		return err
+	}
+
+	if resp.Name != mgr.name {
+		return errors.Errorf("%s calls itself %s, not %s", mgr.executable, resp.Name, mgr.name)
+	}
+
+	return nil
+}
+
+// Dump ...
+func (mgr Manager) Dump() (string, error) {
+	resp, err := mgr.call(OpDump)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Output, nil
+}
+
+// Ensure runs ensure for the plugin and then ensures the symlinks it
+// returns.
+func (mgr Manager) Ensure() error {
+	resp, err := mgr.call(OpEnsure)
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, s := range resp.Symlinks {
+		expanded := mgr.LinkManager.Expand(symlink.Symlink{Link: s.Link, Target: s.Target})
+		err = mgr.LinkManager.Ensure(expanded)
+		if err != nil {
+			printer.Log.Error("failed to create symlink: <fg 1>%s", err)
+			result = multierror.Append(result, errors.Wrapf(err, "unable to ensure %s", expanded))
+		}
+	}
+
+	return result
+}
+
+// Update ...
+func (mgr Manager) Update() error {
+	_, err := mgr.call(OpUpdate)
+	return err
+}
+
+// Status returns the drift the plugin reports, along with that of the
+// symlinks it returns.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	resp, err := mgr.call(OpStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []drift.Drift
+	for _, d := range resp.Drift {
+		drifts = append(drifts, drift.New(d.Item, "%s", d.Reason))
+	}
+
+	for _, s := range resp.Symlinks {
+		if d := mgr.LinkManager.Status(mgr.LinkManager.Expand(symlink.Symlink{Link: s.Link, Target: s.Target})); d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+
+	return drifts, nil
+}
+
+// call runs the plugin with the operation and decodes its response, an
+// error in the response is returned as an error.
+func (mgr Manager) call(operation string) (*Response, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"name":      mgr.name,
+		"operation": operation,
+	})
+
+	request, err := json.Marshal(Request{
+		Operation:  operation,
+		ConfigFile: mgr.configFile,
+		Dotfiles:   mgr.config.Dotfiles,
+		PunktHome:  mgr.config.PunktHome,
+		Settings:   mgr.config.Managers[mgr.name],
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to encode the %s request", operation)
+	}
+
+	// asking for the name only reads, so it is done in dry runs as well
+	commander := run.Commander
+	if operation == OpName {
+		commander = run.ReadOnly
+	}
+
+	var stdout bytes.Buffer
+	cmd := commander(mgr.executable, operation)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	var prefixed *run.Prefixed
+	if mgr.Prefixed {
+		prefixed = run.NewPrefixed(mgr.name)
+		cmd.Stderr = prefixed
+	}
+
+	logger.Debug("running plugin")
+	err = cmd.Run()
+	if prefixed != nil {
+		prefixed.Flush()
+	}
+
+	// a plugin with nothing to return can leave stdout empty
+	var resp Response
+	if stdout.Len() > 0 {
+		if decodeErr := json.Unmarshal(stdout.Bytes(), &resp); decodeErr != nil {
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s failed for %s", operation, mgr.name)
+			}
+
+			return nil, errors.Wrapf(decodeErr, "%s returned an invalid response for %s", mgr.name, operation)
+		}
+	}
+
+	if resp.Error != "" {
+		logger.WithField("error", resp.Error).Error("plugin returned an error")
+		return nil, errors.Errorf("%s failed for %s: %s", operation, mgr.name, resp.Error)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s failed for %s", operation, mgr.name)
+	}
+
+	return &resp, nil
+}
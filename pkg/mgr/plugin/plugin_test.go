@@ -0,0 +1,245 @@
+package plugin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/plugin"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/run"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestPlugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Plugin Suite")
+}
+
+const (
+	name       = "plugged"
+	executable = "/usr/local/bin/punkt-manager-plugged"
+)
+
+var _ = Describe("Plugin Manager", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr *plugin.Manager
+	var linkMgr *testmock.LinkManager
+	var configFile string
+
+	respond := func(resp string) {
+		run.Commander = testmock.FakeWithEnvCommand("TestPluginHelperProcess", "PLUGIN_RESPONSE="+resp)
+	}
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		run.Commander = testmock.FakeCommand("TestPluginHelperProcess")
+
+		config.Managers = map[string]map[string]string{
+			name: {"greeting": "hello"},
+		}
+
+		configFile = filepath.Join(config.PunktHome, name+".toml")
+		linkMgr = new(testmock.LinkManager)
+		mgr = plugin.NewManager(config, snapshot, configFile, name, executable)
+		mgr.LinkManager = linkMgr
+	})
+
+	It("should have the name it is configured as", func() {
+		Expect(mgr.Name()).To(Equal(name))
+	})
+
+	It("should read its condition from the manager config", func() {
+		config.Managers[name]["os"] = "darwin"
+		Expect(mgr.Condition()).To(Equal(machine.Condition{OS: "darwin"}))
+	})
+
+	var _ = Context("Verify", func() {
+		It("should succeed if the plugin responds with its name", func() {
+			Expect(mgr.Verify()).To(Succeed())
+		})
+
+		It("should fail if the plugin calls itself something else", func() {
+			respond(`{"name": "other"}`)
+			Expect(mgr.Verify()).NotTo(Succeed())
+		})
+
+		It("should fail if the plugin doesn't speak the protocol", func() {
+			respond("not json")
+			Expect(mgr.Verify()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Dump", func() {
+		It("should return the output of the plugin", func() {
+			out, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(out).To(Equal(fmt.Sprintf("hello dump %s", configFile)))
+		})
+
+		It("should fail if the plugin returns an error", func() {
+			respond(`{"error": "something went wrong"}`)
+			_, err := mgr.Dump()
+			Expect(err).To(MatchError(ContainSubstring("something went wrong")))
+		})
+
+		It("should fail if the plugin fails", func() {
+			run.Commander = testmock.FakeWithEnvCommand("TestPluginHelperProcess", "FAILING=true")
+			_, err := mgr.Dump()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	var _ = Context("Ensure", func() {
+		It("should succeed if the plugin does", func() {
+			Expect(mgr.Ensure()).To(Succeed())
+			linkMgr.AssertExpectations(GinkgoT())
+		})
+
+		It("should ensure the symlinks the plugin returns", func() {
+			respond(`{"symlinks": [{"link": "~/.foo", "target": "~/.dotfiles/foo"}]}`)
+			linkMgr.On("Ensure", &symlink.Symlink{Link: "~/.foo", Target: "~/.dotfiles/foo"}).Return(nil)
+
+			Expect(mgr.Ensure()).To(Succeed())
+			linkMgr.AssertExpectations(GinkgoT())
+		})
+
+		It("should fail if a symlink can't be ensured", func() {
+			respond(`{"symlinks": [{"link": "~/.foo", "target": "~/.dotfiles/foo"}]}`)
+			linkMgr.On("Ensure", mock.Anything).Return(fmt.Errorf("fail"))
+
+			Expect(mgr.Ensure()).NotTo(Succeed())
+		})
+
+		It("should prefix what the plugin prints with its name when prefixed", func() {
+			out := new(bytes.Buffer)
+			run.Out = out
+			mgr.Prefixed = true
+
+			Expect(mgr.Ensure()).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("%s | ensuring %s\n", name, name)))
+		})
+	})
+
+	var _ = Context("Update", func() {
+		It("should succeed if the plugin does", func() {
+			Expect(mgr.Update()).To(Succeed())
+		})
+
+		It("should fail if the plugin returns an error", func() {
+			respond(`{"error": "something went wrong"}`)
+			Expect(mgr.Update()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Status", func() {
+		It("should report the drift the plugin returns", func() {
+			respond(`{"drift": [{"item": "foo", "reason": "not installed"}]}`)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(drift.New("foo", "not installed")))
+		})
+
+		It("should report the drift of the symlinks the plugin returns", func() {
+			respond(`{"symlinks": [{"link": "~/.foo", "target": "~/.dotfiles/foo"}]}`)
+			d := drift.New("~/.foo", "missing")
+			linkMgr.On("Status", mock.Anything).Return(&d)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(d))
+		})
+
+		It("should report nothing if the plugin returns nothing", func() {
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+	})
+
+	var _ = Context("Discover", func() {
+		var dir string
+		var lookupEnv func(string) (string, bool)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "punkt-plugins")
+			Expect(err).To(BeNil())
+
+			lookupEnv = machine.LookupEnv
+			machine.LookupEnv = func(key string) (string, bool) {
+				return filepath.Join(dir, "missing") + string(filepath.ListSeparator) + dir, key == "PATH"
+			}
+		})
+
+		AfterEach(func() {
+			machine.LookupEnv = lookupEnv
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("should find the executables named as plugins", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "punkt-manager-foo"), nil, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "punkt-manager-bar"), nil, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "punkt"), nil, 0755)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(dir, "punkt-manager-dir"), 0755)).To(Succeed())
+
+			Expect(plugin.Discover()).To(Equal(map[string]string{
+				"foo": filepath.Join(dir, "punkt-manager-foo"),
+			}))
+		})
+	})
+})
+
+// TestPluginHelperProcess acts as a plugin, responding with what is given in
+// PLUGIN_RESPONSE or else echoing what it was asked to do.
+func TestPluginHelperProcess(t *testing.T) {
+	cmd, args, err := testmock.VerifyHelperProcess()
+	if err != nil {
+		return
+	}
+
+	var request plugin.Request
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid request: %s\n", err)
+		os.Exit(1)
+	}
+
+	if cmd != executable || len(args) != 1 || args[0] != request.Operation {
+		fmt.Fprintf(os.Stderr, "should be run with the operation, cmd: %v, args: %v\n", cmd, args)
+		os.Exit(1)
+	}
+
+	if resp, ok := os.LookupEnv("PLUGIN_RESPONSE"); ok {
+		fmt.Print(resp)
+		os.Exit(0)
+	}
+
+	var resp plugin.Response
+	switch request.Operation {
+	case plugin.OpName:
+		resp.Name = name
+	case plugin.OpDump:
+		resp.Output = fmt.Sprintf("%s dump %s", request.Settings["greeting"], request.ConfigFile)
+	case plugin.OpEnsure:
+		fmt.Fprintf(os.Stderr, "ensuring %s\n", name)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,226 @@
+package symlink
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/prompt"
+)
+
+// ensureCopy copies the target to the link if their content differs. A
+// copy that has been edited since it was made is left as is unless the
+// conflict strategy says otherwise, the same goes for a file that wasn't
+// copied there by punkt. If only the link exists it is copied to the
+// target, adding it to the dotfiles.
+func (mgr symlinkManager) ensureCopy(symlink *Symlink) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"link":   symlink.Link,
+		"target": symlink.Target,
+	})
+
+	state, err := mgr.readCopies()
+	if err != nil {
+		return err
+	}
+
+	item := mgr.snapshot.UnexpandHome(symlink.Link)
+	content, err := mgr.snapshot.Read(symlink.Target)
+	if err == fs.ErrNoSuchFile {
+		current, err := mgr.snapshot.Read(symlink.Link)
+		if err != nil {
+			return errors.Wrapf(err, "neither %s nor %s exists", symlink.Target, symlink.Link)
+		}
+
+		logger.Debug("link exists but target doesn't, copying link -> target")
+		err = mgr.snapshot.Save(current, symlink.Target)
+		if err != nil {
+			return errors.Wrapf(err, "failed to copy %s to %s", symlink.Link, symlink.Target)
+		}
+
+		state[item] = hash(current)
+		return mgr.snapshot.SaveToml(state, mgr.copies)
+	} else if err != nil {
+		return err
+	}
+
+	fi, err := mgr.snapshot.Fs.Lstat(symlink.Link)
+	switch {
+	case err != nil:
+		logger.Debug("link doesn't exist, copying")
+
+	case fi.Mode()&os.ModeSymlink != 0:
+		logger.Debug("link is a symlink, replacing it with a copy")
+		err = mgr.snapshot.Fs.Remove(symlink.Link)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove symlink %s", symlink.Link)
+		}
+
+	case fi.IsDir():
+		return errors.Wrapf(ErrConflict, "%s is a directory", symlink.Link)
+
+	default:
+		replace, err := mgr.resolveCopy(symlink, state, content)
+		if err != nil || !replace {
+			return err
+		}
+	}
+
+	err = mgr.snapshot.Save(content, symlink.Link)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy %s to %s", symlink.Target, symlink.Link)
+	}
+
+	logger.Info("copied target to link")
+	printer.Log.Note("copying: <fg 2>%s", mgr.Unexpand(*symlink))
+
+	state[item] = hash(content)
+	return mgr.snapshot.SaveToml(state, mgr.copies)
+}
+
+// resolveCopy handles a file that is already at the link, returning true
+// if it should be replaced by the target. A copy that has been edited since
+// it was made is resolved with the conflict strategy.
+func (mgr symlinkManager) resolveCopy(symlink *Symlink, state map[string]string, content string) (bool, error) {
+	current, err := mgr.snapshot.Read(symlink.Link)
+	if err != nil {
+		return false, err
+	}
+
+	item := mgr.snapshot.UnexpandHome(symlink.Link)
+	previous, copied := state[item]
+	if current == content {
+		printer.Log.Note("copy is up to date: <fg 5>%s", item)
+		if copied && previous == hash(content) {
+			return false, nil
+		}
+
+		state[item] = hash(content)
+		return false, mgr.snapshot.SaveToml(state, mgr.copies)
+	}
+
+	if copied && previous == hash(current) {
+		return true, nil
+	}
+
+	logrus.WithField("link", symlink.Link).Info("copy has been edited since it was made")
+	strategy := symlink.Conflict
+	if strategy == "" {
+		strategy = mgr.config.Conflict
+	}
+
+	return replaceEdited(mgr.snapshot, mgr.backups, symlink.Link, strategy, "not copying, use dump to pull the edits into your dotfiles")
+}
+
+// statusCopy reports if the copy is missing, edited locally or out of date
+// with its target.
+func (mgr symlinkManager) statusCopy(symlink *Symlink) *drift.Drift {
+	item := mgr.snapshot.UnexpandHome(symlink.Link)
+
+	fi, err := mgr.snapshot.Fs.Lstat(symlink.Link)
+	if err != nil {
+		d := drift.New(item, "copy is missing")
+		return &d
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		d := drift.New(item, "is a symlink instead of a copy")
+		return &d
+	}
+
+	content, err := mgr.snapshot.Read(symlink.Target)
+	if err != nil {
+		d := drift.New(item, "%s does not exist", mgr.snapshot.UnexpandHome(symlink.Target))
+		return &d
+	}
+
+	current, err := mgr.snapshot.Read(symlink.Link)
+	if err != nil {
+		d := drift.New(item, "unable to read the copy: %s", err)
+		return &d
+	}
+
+	if current == content {
+		return nil
+	}
+
+	if mgr.edited(symlink, current) {
+		d := drift.New(item, "copy has been edited locally")
+		return &d
+	}
+
+	d := drift.New(item, "copy is out of date with %s", mgr.snapshot.UnexpandHome(symlink.Target))
+	return &d
+}
+
+// Pull offers to copy local edits made to a copy back to its target in the
+// dotfiles, it does nothing for symlinks and unedited copies.
+func (mgr symlinkManager) Pull(symlink *Symlink) error {
+	if !symlink.Copy {
+		return nil
+	}
+
+	current, err := mgr.snapshot.Read(symlink.Link)
+	if err == fs.ErrNoSuchFile {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	content, err := mgr.snapshot.Read(symlink.Target)
+	if err != nil && err != fs.ErrNoSuchFile {
+		return err
+	}
+
+	if current == content || !mgr.edited(symlink, current) {
+		return nil
+	}
+
+	item := mgr.snapshot.UnexpandHome(symlink.Link)
+	if !prompt.Confirm(fmt.Sprintf("%s has been edited, pull the edits into %s?", item, mgr.snapshot.UnexpandHome(symlink.Target))) {
+		printer.Log.Note("not pulling the edits to <fg 5>%s", item)
+		return nil
+	}
+
+	err = mgr.snapshot.Save(current, symlink.Target)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy %s to %s", symlink.Link, symlink.Target)
+	}
+
+	printer.Log.Success("pulled the edits to <fg 2>%s", item)
+
+	state, err := mgr.readCopies()
+	if err != nil {
+		return err
+	}
+
+	state[item] = hash(current)
+	return mgr.snapshot.SaveToml(state, mgr.copies)
+}
+
+// edited returns true if the copy has changed since punkt last copied it
+func (mgr symlinkManager) edited(symlink *Symlink, current string) bool {
+	state, err := mgr.readCopies()
+	if err != nil {
+		return false
+	}
+
+	previous, copied := state[mgr.snapshot.UnexpandHome(symlink.Link)]
+	return !copied || previous != hash(current)
+}
+
+// readCopies reads the hash of what was last copied to each link
+func (mgr symlinkManager) readCopies() (map[string]string, error) {
+	state := make(map[string]string)
+	err := mgr.snapshot.ReadToml(&state, mgr.copies)
+	if err != nil && err != fs.ErrNoSuchFile {
+		return nil, errors.Wrapf(err, "unable to read the copy state")
+	}
+
+	return state, nil
+}
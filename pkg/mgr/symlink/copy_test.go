@@ -0,0 +1,178 @@
+package symlink_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/prompt"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Symlink: Copy", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr symlink.LinkManager
+	var s *symlink.Symlink
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		mgr = symlink.NewLinkManager(config, snapshot)
+
+		s = &symlink.Symlink{
+			Target: filepath.Join(config.Dotfiles, ".vimrc"),
+			Link:   filepath.Join(snapshot.UserHome, ".vimrc"),
+			Copy:   true,
+		}
+		Expect(snapshot.Save("dotfiles", s.Target)).To(Succeed())
+	})
+
+	read := func(file string) string {
+		content, err := snapshot.Read(file)
+		Expect(err).To(BeNil())
+		return content
+	}
+
+	Context("Ensure", func() {
+		It("should copy the target to the link", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Link)).To(Equal("dotfiles"))
+
+			fi, err := snapshot.Fs.Lstat(s.Link)
+			Expect(err).To(BeNil())
+			Expect(fi.Mode() & os.ModeSymlink).To(BeZero())
+		})
+
+		It("should update the copy when the target changes", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("changed", s.Target)).To(Succeed())
+
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Link)).To(Equal("changed"))
+		})
+
+		It("should replace a symlink with a copy", func() {
+			Expect(snapshot.Fs.Symlink(s.Target, s.Link)).To(Succeed())
+
+			Expect(mgr.Ensure(s)).To(Succeed())
+			_, err := snapshot.Fs.Readlink(s.Link)
+			Expect(err).NotTo(BeNil())
+			Expect(read(s.Link)).To(Equal("dotfiles"))
+		})
+
+		It("should not overwrite a copy that was edited locally", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+			Expect(snapshot.Save("changed", s.Target)).To(Succeed())
+
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Link)).To(Equal("edited"))
+		})
+
+		It("should overwrite a copy edited locally when told to", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+
+			s.Conflict = symlink.ConflictOverwrite
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Link)).To(Equal("dotfiles"))
+		})
+
+		It("should keep a copy edited locally when told to adopt it", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+
+			s.Conflict = symlink.ConflictAdopt
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Link)).To(Equal("edited"))
+		})
+
+		It("should copy the link to the target if only the link exists", func() {
+			Expect(snapshot.Fs.Remove(s.Target)).To(Succeed())
+			Expect(snapshot.Save("existing", s.Link)).To(Succeed())
+
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(read(s.Target)).To(Equal("existing"))
+			Expect(read(s.Link)).To(Equal("existing"))
+		})
+
+		It("should fail if neither the link nor the target exists", func() {
+			Expect(snapshot.Fs.Remove(s.Target)).To(Succeed())
+			Expect(mgr.Ensure(s)).NotTo(Succeed())
+		})
+	})
+
+	Context("Status", func() {
+		It("should report nothing when the copy is up to date", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(mgr.Status(s)).To(BeNil())
+		})
+
+		It("should report when the copy is missing", func() {
+			d := mgr.Status(s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("missing"))
+		})
+
+		It("should report when the copy was edited locally", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+
+			d := mgr.Status(s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("edited locally"))
+		})
+
+		It("should report when the target has changed", func() {
+			Expect(mgr.Ensure(s)).To(Succeed())
+			Expect(snapshot.Save("changed", s.Target)).To(Succeed())
+
+			d := mgr.Status(s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("out of date"))
+		})
+	})
+
+	Context("Pull", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			out = new(bytes.Buffer)
+			prompt.Out = out
+			prompt.In = strings.NewReader("y\n")
+			Expect(mgr.Ensure(s)).To(Succeed())
+		})
+
+		It("should pull local edits into the target when confirmed", func() {
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+
+			Expect(mgr.Pull(s)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("~/.vimrc"))
+			Expect(read(s.Target)).To(Equal("edited"))
+			Expect(mgr.Status(s)).To(BeNil())
+		})
+
+		It("should leave the target as is if not confirmed", func() {
+			prompt.In = strings.NewReader("n\n")
+			Expect(snapshot.Save("edited", s.Link)).To(Succeed())
+
+			Expect(mgr.Pull(s)).To(Succeed())
+			Expect(read(s.Target)).To(Equal("dotfiles"))
+		})
+
+		It("should not ask if the copy hasn't been edited", func() {
+			Expect(snapshot.Save("changed", s.Target)).To(Succeed())
+
+			Expect(mgr.Pull(s)).To(Succeed())
+			Expect(out.String()).To(BeEmpty())
+			Expect(read(s.Target)).To(Equal("changed"))
+		})
+	})
+})
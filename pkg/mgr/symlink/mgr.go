@@ -2,47 +2,103 @@ package symlink
 
 import (
 	"fmt"
+	"sync"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/run"
 )
 
 // Manager ...
 type Manager struct {
-	LinkManager LinkManager
-	snapshot    fs.Snapshot
-	configFile  string
-	config      conf.Config
+	LinkManager     LinkManager
+	TemplateManager TemplateManager
+	SecretManager   SecretManager
+	// Mutex is held while ensuring if set, it is shared with the others
+	// ensuring symlinks at the same time as they keep state in the same
+	// files.
+	Mutex      *sync.Mutex
+	snapshot   fs.Snapshot
+	configFile string
+	config     conf.Config
 }
 
 // Symlink describes a symlink, i.e. what it links from and what it links to
 type Symlink struct {
 	Target string
 	Link   string
+	// Conflict is the strategy to use if something already exists at Link,
+	// if empty the configured default is used.
+	Conflict string
+	// Copy makes the link a copy of the target instead of a symlink
+	Copy bool
+	// OnChange is a shell command run when ensure creates or changes the
+	// link
+	OnChange string
+	// Condition limits which machines the symlink is created on
+	machine.Condition
 }
 
 // Config ...
 type Config struct {
-	Symlinks []Symlink
+	Symlinks  []Symlink
+	Templates []Template
+	Secrets   []Secret
 }
 
 func (symlink Symlink) String() string {
 	return fmt.Sprintf("%s -> %s", symlink.Link, symlink.Target)
 }
 
-// UnmarshalTOML unmarshals a map of link -> target
+// UnmarshalTOML unmarshals a map of link -> target, where target is either
+// the target path or a table with the target and further options. A table
+// with a template instead of a target is rendered to the link location and
+// one with a secret is decrypted to it. Tables can also have a host, os,
+// arch or env condition limiting which machines they apply to.
 func (config *Config) UnmarshalTOML(data interface{}) error {
 	links, _ := data.(map[string]interface{})
 	for link, val := range links {
-		target, _ := val.(string)
-
-		s := Symlink{
-			Link:   link,
-			Target: target,
+		s := Symlink{Link: link}
+
+		switch v := val.(type) {
+		case string:
+			s.Target = v
+		case map[string]interface{}:
+			s.Target, _ = v["target"].(string)
+			s.Conflict, _ = v["conflict"].(string)
+			s.Copy, _ = v["copy"].(bool)
+			s.OnChange, _ = v["on_change"].(string)
+			s.Host, _ = v["host"].(string)
+			s.OS, _ = v["os"].(string)
+			s.Arch, _ = v["arch"].(string)
+			s.Env, _ = v["env"].(string)
+
+			if source, ok := v["template"].(string); ok {
+				config.Templates = append(config.Templates, Template{
+					Source:      source,
+					Destination: link,
+					Conflict:    s.Conflict,
+					Condition:   s.Condition,
+				})
+				continue
+			}
+
+			if source, ok := v["secret"].(string); ok {
+				config.Secrets = append(config.Secrets, Secret{
+					Source:      source,
+					Destination: link,
+					Conflict:    s.Conflict,
+					Condition:   s.Condition,
+				})
+				continue
+			}
 		}
 
 		config.Symlinks = append(config.Symlinks, s)
@@ -52,27 +108,87 @@ func (config *Config) UnmarshalTOML(data interface{}) error {
 }
 
 // AsMap returns the configuration as a map, which is the format the
-// symlinks should be stored in..
-func (config Config) AsMap() map[string]string {
-	mapping := make(map[string]string)
+// symlinks should be stored in. Symlinks with only a target are stored as
+// link = target, others, templates and secrets as a table.
+func (config Config) AsMap() map[string]interface{} {
+	mapping := make(map[string]interface{})
 	for _, s := range config.Symlinks {
-		mapping[s.Link] = s.Target
+		entry := table(s.Condition)
+		if s.Conflict == "" && !s.Copy && s.OnChange == "" && len(entry) == 0 {
+			mapping[s.Link] = s.Target
+			continue
+		}
+
+		entry["target"] = s.Target
+		if s.Conflict != "" {
+			entry["conflict"] = s.Conflict
+		}
+		if s.Copy {
+			entry["copy"] = true
+		}
+		if s.OnChange != "" {
+			entry["on_change"] = s.OnChange
+		}
+
+		mapping[s.Link] = entry
+	}
+
+	for _, t := range config.Templates {
+		entry := table(t.Condition)
+		entry["template"] = t.Source
+		if t.Conflict != "" {
+			entry["conflict"] = t.Conflict
+		}
+
+		mapping[t.Destination] = entry
+	}
+
+	for _, secret := range config.Secrets {
+		entry := table(secret.Condition)
+		entry["secret"] = secret.Source
+		if secret.Conflict != "" {
+			entry["conflict"] = secret.Conflict
+		}
+
+		mapping[secret.Destination] = entry
 	}
 	return mapping
 }
 
+// table returns the start of a table for an entry, with its condition
+func table(condition machine.Condition) map[string]interface{} {
+	entry := make(map[string]interface{})
+	for key, val := range condition.AsMap() {
+		entry[key] = val
+	}
+
+	return entry
+}
+
 // NewManager ...
 func NewManager(c conf.Config, snapshot fs.Snapshot, configFile string) *Manager {
 	return &Manager{
-		LinkManager: NewLinkManager(c, snapshot),
-		snapshot:    snapshot,
-		config:      c,
-		configFile:  configFile,
+		LinkManager:     NewLinkManager(c, snapshot),
+		TemplateManager: NewTemplateManager(c, snapshot),
+		SecretManager:   NewSecretManager(c, snapshot),
+		snapshot:        snapshot,
+		config:          c,
+		configFile:      configFile,
 	}
 }
 
 // Add ...
 func (mgr Manager) Add(target, newLocation string) (*Symlink, error) {
+	return mgr.add(target, newLocation, false)
+}
+
+// AddCopy adds the target like Add, but keeps a copy of it at the link
+// location instead of a symlink.
+func (mgr Manager) AddCopy(target, newLocation string) (*Symlink, error) {
+	return mgr.add(target, newLocation, true)
+}
+
+func (mgr Manager) add(target, newLocation string, asCopy bool) (*Symlink, error) {
 	absTarget, err := mgr.snapshot.AsAbsolute(target)
 	if err != nil {
 		printer.Log.Error("target file or directory does not exist: <fg 1>%s", target)
@@ -80,6 +196,7 @@ func (mgr Manager) Add(target, newLocation string) (*Symlink, error) {
 	}
 
 	symlink := mgr.LinkManager.New(newLocation, absTarget)
+	symlink.Copy = asCopy
 	err = mgr.LinkManager.Ensure(symlink)
 	if err != nil {
 		printer.Log.Error("failed to create symlink: <fg 1>%s", err)
@@ -96,6 +213,37 @@ func (mgr Manager) Add(target, newLocation string) (*Symlink, error) {
 	return symlink, err
 }
 
+// AddSecret encrypts the file into the dotfiles, at newLocation if given,
+// and stores it as a secret to decrypt back to where the file is.
+func (mgr Manager) AddSecret(file, newLocation string) (*Secret, error) {
+	absFile, err := mgr.snapshot.AsAbsolute(file)
+	if err != nil {
+		printer.Log.Error("file does not exist: <fg 1>%s", file)
+		return nil, err
+	}
+
+	s, err := mgr.SecretManager.Add(absFile, mgr.snapshot.ExpandHome(newLocation))
+	if err != nil {
+		printer.Log.Error("failed to encrypt secret: <fg 1>%s", err)
+		return nil, errors.Wrapf(err, "failed to encrypt %s", file)
+	}
+
+	err = mgr.SecretManager.Ensure(*s)
+	if err != nil {
+		printer.Log.Error("failed to decrypt secret: <fg 1>%s", err)
+		return nil, errors.Wrapf(err, "failed to ensure %s", s)
+	}
+
+	storedSecret, err := mgr.addSecretToConfiguration(s)
+	if err == nil {
+		printer.Log.Success("secret added: <fg 2>%s", storedSecret)
+	} else {
+		printer.Log.Error("failed to add secret: <fg 1>%s", err)
+	}
+
+	return s, err
+}
+
 // Remove ...
 func (mgr Manager) Remove(link string) error {
 	absLink, err := mgr.snapshot.AsAbsolute(link)
@@ -147,8 +295,14 @@ func (mgr Manager) addToConfiguration(new *Symlink) (*Symlink, error) {
 	}
 
 	unexpanded := mgr.LinkManager.Unexpand(*new)
-	for _, existing := range saved.Symlinks {
+	for i, existing := range saved.Symlinks {
 		if unexpanded.Target == existing.Target && unexpanded.Link == existing.Link {
+			if existing.Copy != unexpanded.Copy {
+				logrus.WithField("symlink", unexpanded).Info("symlink already saved, updating its copy mode")
+				saved.Symlinks[i].Copy = unexpanded.Copy
+				return unexpanded, mgr.snapshot.SaveToml(saved.AsMap(), mgr.configFile)
+			}
+
 			printer.Log.Note("symlink is already stored")
 			logrus.WithField("symlink", unexpanded).Info("symlink already saved, nothing new to store")
 			return unexpanded, nil
@@ -161,6 +315,32 @@ func (mgr Manager) addToConfiguration(new *Symlink) (*Symlink, error) {
 	return unexpanded, mgr.snapshot.SaveToml(saved.AsMap(), mgr.configFile)
 }
 
+func (mgr Manager) addSecretToConfiguration(new *Secret) (*Secret, error) {
+	logrus.WithField("newSecret", new).Info("Storing secret in configuration")
+	saved, err := mgr.readConfiguration()
+	if err != nil && err != fs.ErrNoSuchFile {
+		return nil, err
+	}
+
+	unexpanded := Secret{
+		Source:      mgr.snapshot.UnexpandHome(new.Source),
+		Destination: mgr.snapshot.UnexpandHome(new.Destination),
+	}
+
+	for i, existing := range saved.Secrets {
+		if unexpanded.Destination == existing.Destination {
+			logrus.WithField("secret", unexpanded).Info("secret already saved, updating its source")
+			unexpanded.Conflict = existing.Conflict
+			unexpanded.Condition = existing.Condition
+			saved.Secrets[i] = unexpanded
+			return &unexpanded, mgr.snapshot.SaveToml(saved.AsMap(), mgr.configFile)
+		}
+	}
+
+	saved.Secrets = append(saved.Secrets, unexpanded)
+	return &unexpanded, mgr.snapshot.SaveToml(saved.AsMap(), mgr.configFile)
+}
+
 func (mgr Manager) removeFromConfiguration(symlink Symlink) (*Symlink, error) {
 	var config Config
 	err := mgr.snapshot.ReadToml(&config, mgr.configFile)
@@ -202,11 +382,182 @@ func (mgr Manager) Name() string {
 	return "symlink"
 }
 
-// Dump ...
-func (mgr Manager) Dump() (string, error) { return "", nil }
+// Dump offers to pull the local edits made to copies back into the
+// dotfiles, the symlink configuration itself is only changed by add and
+// remove so nothing is returned.
+func (mgr Manager) Dump() (string, error) {
+	var config Config
+	err := mgr.snapshot.ReadToml(&config, mgr.configFile)
+	if err == fs.ErrNoSuchFile {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	var result error
+	for _, s := range config.Symlinks {
+		if !s.Copy {
+			continue
+		}
+
+		err = mgr.LinkManager.Pull(mgr.LinkManager.Expand(s))
+		if err != nil {
+			printer.Log.Error("failed to pull edits: <fg 1>%s", err)
+			result = multierror.Append(result, errors.Wrapf(err, "unable to pull %s", s))
+		}
+	}
+
+	return "", result
+}
 
 // Update ...
 func (mgr Manager) Update() error { return nil }
 
-// Ensure ...
-func (mgr Manager) Ensure() error { return nil }
+// Ensure creates all of the stored symlinks, renders the templates and
+// decrypts the secrets, skipping those whose condition doesn't match the machine.
+func (mgr Manager) Ensure() error {
+	if mgr.Mutex != nil {
+		mgr.Mutex.Lock()
+		defer mgr.Mutex.Unlock()
+	}
+
+	config, err := mgr.readConfiguration()
+	if err != nil {
+		if err == fs.ErrNoSuchFile {
+			return nil
+		}
+
+		return err
+	}
+
+	return mgr.EnsureConfig(config)
+}
+
+// EnsureConfig creates the symlinks, renders the templates and decrypts the
+// secrets of the given configuration, it is used for those stored with
+// other managers as well.
+func (mgr Manager) EnsureConfig(config Config) error {
+	var result error
+	for _, s := range config.Symlinks {
+		if !mgr.applies(s.Condition, s.String(), &result) {
+			continue
+		}
+
+		err := EnsureLink(mgr.LinkManager, s)
+		if err != nil {
+			printer.Log.Error("failed to create symlink: <fg 1>%s", err)
+			result = multierror.Append(result, errors.Wrapf(err, "unable to ensure %s", s))
+		}
+	}
+
+	for _, t := range config.Templates {
+		if !mgr.applies(t.Condition, t.String(), &result) {
+			continue
+		}
+
+		err := mgr.TemplateManager.Ensure(t)
+		if err != nil {
+			printer.Log.Error("failed to render template: <fg 1>%s", err)
+			result = multierror.Append(result, errors.Wrapf(err, "unable to render %s", t))
+		}
+	}
+
+	for _, secret := range config.Secrets {
+		if !mgr.applies(secret.Condition, secret.String(), &result) {
+			continue
+		}
+
+		err := mgr.SecretManager.Ensure(secret)
+		if err != nil {
+			printer.Log.Error("failed to decrypt secret: <fg 1>%s", err)
+			result = multierror.Append(result, errors.Wrapf(err, "unable to decrypt %s", secret))
+		}
+	}
+
+	return result
+}
+
+// Status reports the stored symlinks that are missing, broken or pointing
+// somewhere other than their configured target, and the templates that
+// are missing, out of date or edited locally, and the secrets that are
+// missing, differ or can be read by others.
+func (mgr Manager) Status() ([]drift.Drift, error) {
+	config, err := mgr.readConfiguration()
+	if err != nil {
+		if err == fs.ErrNoSuchFile {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return mgr.StatusConfig(config)
+}
+
+// StatusConfig reports the drift of the symlinks, templates and secrets of
+// the given configuration.
+func (mgr Manager) StatusConfig(config Config) ([]drift.Drift, error) {
+	var result error
+	var drifts []drift.Drift
+	for _, s := range config.Symlinks {
+		if !mgr.applies(s.Condition, s.String(), &result) {
+			continue
+		}
+
+		if d := mgr.LinkManager.Status(mgr.LinkManager.Expand(s)); d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+
+	for _, t := range config.Templates {
+		if !mgr.applies(t.Condition, t.String(), &result) {
+			continue
+		}
+
+		if d := mgr.TemplateManager.Status(t); d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+
+	for _, secret := range config.Secrets {
+		if !mgr.applies(secret.Condition, secret.String(), &result) {
+			continue
+		}
+
+		if d := mgr.SecretManager.Status(secret); d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+
+	return drifts, result
+}
+
+// EnsureLink ensures the symlink with the link manager, running its
+// on_change hook if the link was created or changed.
+func EnsureLink(linkMgr LinkManager, s Symlink) error {
+	expanded := linkMgr.Expand(s)
+	if s.OnChange == "" {
+		return linkMgr.Ensure(expanded)
+	}
+
+	before := linkMgr.Status(expanded)
+	err := linkMgr.Ensure(expanded)
+	if err != nil || before == nil || linkMgr.Status(expanded) != nil {
+		return err
+	}
+
+	printer.Log.Note("running on_change hook for <fg 5>%s", s.Link)
+	return run.Hook(s.OnChange, "")
+}
+
+// applies checks if the condition matches the machine, adding the error to
+// result if it can't be checked.
+func (mgr Manager) applies(condition machine.Condition, item string, result *error) bool {
+	ok, err := condition.Applies(item)
+	if err != nil {
+		printer.Log.Error("failed to check condition: <fg 1>%s", err)
+		*result = multierror.Append(*result, errors.Wrapf(err, "unable to check the condition of %s", item))
+	}
+
+	return ok
+}
@@ -1,8 +1,11 @@
 package symlink_test
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -10,8 +13,12 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/run"
+	"github.com/mbark/punkt/pkg/secret"
 	"github.com/mbark/punkt/testmock"
 )
 
@@ -59,6 +66,21 @@ var _ = Describe("Symlink: Manager", func() {
 			Expect(err).To(BeNil())
 			Expect(out).To(Equal(""))
 		})
+
+		It("should pull the edits to copies", func() {
+			c := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/link", Target: "~/.dotfiles/link"},
+				{Link: "~/copy", Target: "~/.dotfiles/copy", Copy: true},
+			}}
+			Expect(snapshot.SaveToml(c.AsMap(), configFile)).To(Succeed())
+			linkMgr.On("Pull", mock.Anything).Return(nil)
+
+			out, err := mgr.Dump()
+			Expect(err).To(BeNil())
+			Expect(out).To(Equal(""))
+			linkMgr.AssertNumberOfCalls(GinkgoT(), "Pull", 1)
+			linkMgr.AssertCalled(GinkgoT(), "Pull", &c.Symlinks[1])
+		})
 	})
 
 	var _ = Context("Update", func() {
@@ -75,6 +97,184 @@ var _ = Describe("Symlink: Manager", func() {
 		})
 	})
 
+	var _ = Context("Config", func() {
+		It("should store symlinks with a conflict strategy as a table", func() {
+			expected := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/plain", Target: "~/.dotfiles/plain"},
+				{Link: "~/table", Target: "~/.dotfiles/table", Conflict: symlink.ConflictBackup},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Symlinks).To(ConsistOf(expected.Symlinks))
+		})
+
+		It("should store symlinks with a condition as a table", func() {
+			expected := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/work", Target: "~/.dotfiles/work", Condition: machine.Condition{Host: "work-*", OS: "linux"}},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Symlinks).To(ConsistOf(expected.Symlinks))
+		})
+
+		It("should store symlinks with an on_change hook as a table", func() {
+			expected := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/.fonts", Target: "~/.dotfiles/fonts", OnChange: "fc-cache"},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Symlinks).To(ConsistOf(expected.Symlinks))
+		})
+
+		It("should store copies as a table", func() {
+			expected := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/copy", Target: "~/.dotfiles/copy", Copy: true},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Symlinks).To(ConsistOf(expected.Symlinks))
+		})
+
+		It("should store templates as a table", func() {
+			expected := symlink.Config{Templates: []symlink.Template{
+				{Destination: "~/.gitconfig", Source: "~/.dotfiles/gitconfig.tmpl"},
+				{Destination: "~/.npmrc", Source: "~/.dotfiles/npmrc.tmpl", Conflict: symlink.ConflictOverwrite},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Templates).To(ConsistOf(expected.Templates))
+			Expect(actual.Symlinks).To(BeEmpty())
+		})
+
+		It("should store secrets as a table", func() {
+			expected := symlink.Config{Secrets: []symlink.Secret{
+				{Destination: "~/.netrc", Source: "~/.dotfiles/netrc.asc", Condition: machine.Condition{OS: "linux"}},
+			}}
+			Expect(snapshot.SaveToml(expected.AsMap(), configFile)).To(Succeed())
+
+			var actual symlink.Config
+			Expect(snapshot.ReadToml(&actual, configFile)).To(Succeed())
+			Expect(actual.Secrets).To(ConsistOf(expected.Secrets))
+			Expect(actual.Symlinks).To(BeEmpty())
+		})
+	})
+
+	var _ = Context("Ensure", func() {
+		It("should succeed if there is no configuration", func() {
+			Expect(mgr.Ensure()).To(Succeed())
+		})
+
+		It("should ensure the stored symlinks", func() {
+			_, err := mgr.Add(existingFile, "/some/where")
+			Expect(err).To(BeNil())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			linkMgr.AssertNumberOfCalls(GinkgoT(), "Ensure", 2)
+		})
+
+		It("should skip the symlinks whose condition doesn't match", func() {
+			machine.Hostname = func() (string, error) { return "laptop", nil }
+			c := symlink.Config{Symlinks: []symlink.Symlink{
+				{Link: "~/work", Target: "~/.dotfiles/work", Condition: machine.Condition{Host: "work-*"}},
+				{Link: "~/home", Target: "~/.dotfiles/home", Condition: machine.Condition{Host: "lap*"}},
+			}}
+			Expect(snapshot.SaveToml(c.AsMap(), configFile)).To(Succeed())
+
+			Expect(mgr.Ensure()).To(Succeed())
+			linkMgr.AssertNumberOfCalls(GinkgoT(), "Ensure", 1)
+			linkMgr.AssertCalled(GinkgoT(), "Ensure", &c.Symlinks[1])
+		})
+
+		Context("on_change", func() {
+			var out *bytes.Buffer
+
+			BeforeEach(func() {
+				out = new(bytes.Buffer)
+				run.Out = out
+				run.Commander = testmock.FakeCommand("TestSymlinkHelperProcess")
+
+				c := symlink.Config{Symlinks: []symlink.Symlink{
+					{Link: "~/.fonts", Target: "~/.dotfiles/fonts", OnChange: "fc-cache"},
+				}}
+				Expect(snapshot.SaveToml(c.AsMap(), configFile)).To(Succeed())
+			})
+
+			It("should run the hook if the link was created or changed", func() {
+				d := drift.New("~/.fonts", "missing")
+				linkMgr.On("Status", mock.Anything).Return(&d).Once()
+				linkMgr.On("Status", mock.Anything).Return(nil)
+
+				Expect(mgr.Ensure()).To(Succeed())
+				Expect(out.String()).To(Equal("sh -c fc-cache\n"))
+			})
+
+			It("should not run the hook if the link was already there", func() {
+				linkMgr.On("Status", mock.Anything).Return(nil)
+
+				Expect(mgr.Ensure()).To(Succeed())
+				Expect(out.String()).To(BeEmpty())
+			})
+
+			It("should not run the hook if the link wasn't created", func() {
+				d := drift.New("~/.fonts", "missing")
+				linkMgr.On("Status", mock.Anything).Return(&d)
+
+				Expect(mgr.Ensure()).To(Succeed())
+				Expect(out.String()).To(BeEmpty())
+			})
+
+			It("should fail if the hook fails", func() {
+				run.Commander = testmock.FakeWithEnvCommand("TestSymlinkHelperProcess", "FAILING=true")
+				d := drift.New("~/.fonts", "missing")
+				linkMgr.On("Status", mock.Anything).Return(&d).Once()
+				linkMgr.On("Status", mock.Anything).Return(nil)
+
+				Expect(mgr.Ensure()).NotTo(Succeed())
+			})
+		})
+
+		It("should fail if some symlink can't be ensured", func() {
+			_, err := mgr.Add(existingFile, "/some/where")
+			Expect(err).To(BeNil())
+
+			linkMgr = new(testmock.LinkManager)
+			mgr.LinkManager = linkMgr
+			linkMgr.On("Ensure", mock.Anything).Return(fmt.Errorf("fail"))
+
+			Expect(mgr.Ensure()).NotTo(Succeed())
+		})
+	})
+
+	var _ = Context("Status", func() {
+		It("should report nothing if there is no configuration", func() {
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		It("should report the drift of stored symlinks", func() {
+			_, err := mgr.Add(existingFile, "/some/where")
+			Expect(err).To(BeNil())
+
+			d := drift.New("link", "symlink is missing")
+			linkMgr.On("Status", mock.Anything).Return(&d)
+
+			drifts, err := mgr.Status()
+			Expect(err).To(BeNil())
+			Expect(drifts).To(ConsistOf(d))
+		})
+	})
+
 	var _ = Context("Add", func() {
 		It("should make the target path absolute", func() {
 			target := filepath.Base(existingFile)
@@ -115,6 +315,16 @@ var _ = Describe("Symlink: Manager", func() {
 			Expect(err).NotTo(BeNil())
 		})
 
+		It("should save a copy added as a copy", func() {
+			s, err := mgr.AddCopy(existingFile, "/some/where")
+			Expect(err).To(BeNil())
+			Expect(s.Copy).To(BeTrue())
+
+			var c symlink.Config
+			Expect(snapshot.ReadToml(&c, configFile)).To(Succeed())
+			Expect(c.Symlinks).To(ConsistOf(*s))
+		})
+
 		It("should save the symlink added", func() {
 			s, err := mgr.Add(existingFile, "/some/where")
 			Expect(err).To(BeNil())
@@ -153,6 +363,27 @@ var _ = Describe("Symlink: Manager", func() {
 		})
 	})
 
+	var _ = Context("AddSecret", func() {
+		It("should store the secret added", func() {
+			secret.Prompt = func() ([]byte, error) { return []byte("pass"), nil }
+
+			s, err := mgr.AddSecret(existingFile, "")
+			Expect(err).To(BeNil())
+
+			var c symlink.Config
+			Expect(snapshot.ReadToml(&c, configFile)).To(Succeed())
+			Expect(c.Secrets).To(ConsistOf(symlink.Secret{
+				Source:      snapshot.UnexpandHome(s.Source),
+				Destination: snapshot.UnexpandHome(s.Destination),
+			}))
+		})
+
+		It("should fail if the file to add doesn't exist", func() {
+			_, err := mgr.AddSecret("/a/file", "")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
 	var _ = Context("Remove", func() {
 		It("should succeed when removing a link that was added", func() {
 			s, err := mgr.Add(existingFile, "")
@@ -207,3 +438,13 @@ var _ = Describe("Symlink: Manager", func() {
 		})
 	})
 })
+
+func TestSymlinkHelperProcess(t *testing.T) {
+	cmd, args, err := testmock.VerifyHelperProcess()
+	if err != nil {
+		return
+	}
+
+	fmt.Println(strings.Join(append([]string{cmd}, args...), " "))
+	os.Exit(0)
+}
@@ -0,0 +1,235 @@
+package symlink
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/printer"
+	"github.com/mbark/punkt/pkg/secret"
+)
+
+// secretExtension is added to the dotfiles path of a secret when it isn't
+// given, the secrets are stored as armored OpenPGP messages.
+const secretExtension = ".asc"
+
+// Secret describes a file that is stored encrypted in the dotfiles, it is
+// decrypted to Destination, readable only by the user, instead of being
+// symlinked.
+type Secret struct {
+	Source      string
+	Destination string
+	// Conflict is the strategy to use if the decrypted file has been edited
+	// locally, only backup and overwrite will replace it.
+	Conflict string
+	// Condition limits which machines the secret is decrypted on
+	machine.Condition
+}
+
+func (s Secret) String() string {
+	return fmt.Sprintf("%s <- %s", s.Destination, s.Source)
+}
+
+// SecretManager encrypts files into the dotfiles and decrypts them back to
+// where they are used.
+type SecretManager interface {
+	Add(file, source string) (*Secret, error)
+	Ensure(s Secret) error
+	Status(s Secret) *drift.Drift
+}
+
+type secretManager struct {
+	snapshot   fs.Snapshot
+	config     conf.Config
+	backups    string
+	passphrase []byte
+}
+
+// NewSecretManager ...
+func NewSecretManager(config conf.Config, snapshot fs.Snapshot) SecretManager {
+	return &secretManager{
+		snapshot: snapshot,
+		config:   config,
+		backups:  filepath.Join(config.PunktHome, "backups", time.Now().Format("20060102-150405.000000000")),
+	}
+}
+
+// Add encrypts the file and saves it to source. If no source is given it
+// is placed at the same path relative to the dotfiles as the file has to
+// the home directory.
+func (mgr *secretManager) Add(file, source string) (*Secret, error) {
+	if source == "" {
+		derived, err := deriveLink(file, mgr.snapshot.UserHome, mgr.config.Dotfiles)
+		if err != nil {
+			return nil, err
+		}
+
+		source = derived + secretExtension
+	}
+
+	content, err := mgr.snapshot.Read(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+
+	passphrase, err := mgr.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := secret.Encrypt([]byte(content), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mgr.snapshot.Save(string(encrypted), source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to write %s", source)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file":   file,
+		"source": source,
+	}).Info("encrypted secret")
+
+	return &Secret{Source: source, Destination: file}, nil
+}
+
+// Ensure decrypts the secret to its destination, if the destination
+// differs from the secret it is left as is unless the conflict strategy
+// says otherwise.
+func (mgr *secretManager) Ensure(s Secret) error {
+	s = mgr.expand(s)
+	logger := logrus.WithFields(logrus.Fields{
+		"source":      s.Source,
+		"destination": s.Destination,
+	})
+
+	out, err := mgr.decrypt(s)
+	if err != nil {
+		return err
+	}
+
+	destination := mgr.snapshot.UnexpandHome(s.Destination)
+	current, err := mgr.snapshot.Read(s.Destination)
+	switch {
+	case err == fs.ErrNoSuchFile:
+		logger.Debug("destination doesn't exist, decrypting")
+
+	case err != nil:
+		return err
+
+	case current == string(out) && mgr.private(s.Destination):
+		printer.Log.Note("secret is up to date: <fg 5>%s", destination)
+		return nil
+
+	case current == string(out):
+		logger.Info("destination can be read by others, replacing it")
+
+	default:
+		logger.Info("destination differs from the secret")
+		strategy := s.Conflict
+		if strategy == "" {
+			strategy = mgr.config.Conflict
+		}
+
+		replace, err := replaceEdited(mgr.snapshot, mgr.backups, s.Destination, strategy, "not decrypting the secret")
+		if err != nil || !replace {
+			return err
+		}
+	}
+
+	err = mgr.snapshot.SavePrivate(out, s.Destination)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", s.Destination)
+	}
+
+	logger.Info("decrypted secret")
+	printer.Log.Note("decrypted secret: <fg 2>%s", destination)
+	return nil
+}
+
+// Status reports if the decrypted secret is missing, differs from the
+// secret or can be read by others.
+func (mgr *secretManager) Status(s Secret) *drift.Drift {
+	s = mgr.expand(s)
+	item := mgr.snapshot.UnexpandHome(s.Destination)
+
+	out, err := mgr.decrypt(s)
+	if err != nil {
+		d := drift.New(item, "unable to decrypt secret: %s", err)
+		return &d
+	}
+
+	current, err := mgr.snapshot.Read(s.Destination)
+	if err != nil {
+		d := drift.New(item, "decrypted secret is missing")
+		return &d
+	}
+
+	if current != string(out) {
+		d := drift.New(item, "decrypted secret differs from the one in the dotfiles")
+		return &d
+	}
+
+	if !mgr.private(s.Destination) {
+		d := drift.New(item, "decrypted secret can be read by others")
+		return &d
+	}
+
+	return nil
+}
+
+func (mgr *secretManager) decrypt(s Secret) ([]byte, error) {
+	content, err := mgr.snapshot.Read(s.Source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read secret %s", s.Source)
+	}
+
+	passphrase, err := mgr.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := secret.Decrypt([]byte(content), passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %s", s.Source)
+	}
+
+	return out, nil
+}
+
+// getPassphrase returns the passphrase, only reading it the first time it
+// is needed.
+func (mgr *secretManager) getPassphrase() ([]byte, error) {
+	if mgr.passphrase != nil {
+		return mgr.passphrase, nil
+	}
+
+	passphrase, err := secret.Passphrase(mgr.snapshot, mgr.config.SecretKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr.passphrase = passphrase
+	return passphrase, nil
+}
+
+// private returns true if only the user can access the file
+func (mgr *secretManager) private(file string) bool {
+	fi, err := mgr.snapshot.Fs.Stat(file)
+	return err == nil && fi.Mode().Perm()&0077 == 0
+}
+
+func (mgr *secretManager) expand(s Secret) Secret {
+	s.Source = mgr.snapshot.ExpandHome(s.Source)
+	s.Destination = mgr.snapshot.ExpandHome(s.Destination)
+	return s
+}
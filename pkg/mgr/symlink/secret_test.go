@@ -0,0 +1,182 @@
+package symlink_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/journal"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/pkg/secret"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Symlink: Secret Manager", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr symlink.SecretManager
+	var file string
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		secret.Prompt = func() ([]byte, error) { return []byte("pass"), nil }
+
+		file = filepath.Join(snapshot.UserHome, ".netrc")
+		Expect(snapshot.Save("machine example.com", file)).To(Succeed())
+
+		mgr = symlink.NewSecretManager(config, snapshot)
+	})
+
+	read := func() string {
+		content, err := snapshot.Read(file)
+		Expect(err).To(BeNil())
+		return content
+	}
+
+	Context("Add", func() {
+		It("should encrypt the file into the dotfiles", func() {
+			s, err := mgr.Add(file, "")
+			Expect(err).To(BeNil())
+			Expect(s.Source).To(Equal(filepath.Join(config.Dotfiles, ".netrc.asc")))
+
+			encrypted, err := snapshot.Read(s.Source)
+			Expect(err).To(BeNil())
+			Expect(encrypted).NotTo(ContainSubstring("example.com"))
+		})
+
+		It("should use the given location", func() {
+			s, err := mgr.Add(file, "/secrets/netrc")
+			Expect(err).To(BeNil())
+			Expect(s.Source).To(Equal("/secrets/netrc"))
+		})
+
+		It("should fail for files outside of the home directory without a location", func() {
+			Expect(snapshot.Save("content", "/etc/file")).To(Succeed())
+			_, err := mgr.Add("/etc/file", "")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("Ensure", func() {
+		var s *symlink.Secret
+
+		BeforeEach(func() {
+			var err error
+			s, err = mgr.Add(file, "")
+			Expect(err).To(BeNil())
+		})
+
+		It("should decrypt the secret readable only by the user", func() {
+			Expect(snapshot.Fs.Remove(file)).To(Succeed())
+
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			Expect(read()).To(Equal("machine example.com"))
+
+			fi, err := snapshot.Fs.Stat(file)
+			Expect(err).To(BeNil())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("should restrict the permissions of an existing file", func() {
+			Expect(mgr.Ensure(*s)).To(Succeed())
+
+			fi, err := snapshot.Fs.Stat(file)
+			Expect(err).To(BeNil())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("should not overwrite a file that differs", func() {
+			Expect(snapshot.Save("edited", file)).To(Succeed())
+
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			Expect(read()).To(Equal("edited"))
+		})
+
+		It("should overwrite a file that differs when told to", func() {
+			Expect(snapshot.Save("edited", file)).To(Succeed())
+
+			s.Conflict = symlink.ConflictOverwrite
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			Expect(read()).To(Equal("machine example.com"))
+		})
+
+		It("should not keep a copy of the replaced file in the journal", func() {
+			dir := filepath.Join(config.PunktHome, "journal")
+			run := journal.New(snapshot, dir).Start()
+			journaled := snapshot
+			journaled.Fs = journal.NewFilesystem(run, snapshot.Fs)
+			mgr = symlink.NewSecretManager(config, journaled)
+
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			s.Conflict = symlink.ConflictOverwrite
+			Expect(snapshot.Save("edited", file)).To(Succeed())
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			Expect(read()).To(Equal("machine example.com"))
+
+			for _, entry := range run.Entries {
+				Expect(entry.Backup).To(BeEmpty())
+			}
+
+			_, err := snapshot.Fs.Stat(dir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("should fail with the wrong passphrase", func() {
+			secret.Prompt = func() ([]byte, error) { return []byte("wrong"), nil }
+			mgr = symlink.NewSecretManager(config, snapshot)
+
+			Expect(mgr.Ensure(*s)).NotTo(Succeed())
+		})
+
+		It("should handle home relative paths", func() {
+			Expect(snapshot.Fs.Remove(file)).To(Succeed())
+
+			Expect(mgr.Ensure(symlink.Secret{
+				Source:      "~/.dotfiles/.netrc.asc",
+				Destination: "~/.netrc",
+			})).To(Succeed())
+			Expect(read()).To(Equal("machine example.com"))
+		})
+	})
+
+	Context("Status", func() {
+		var s *symlink.Secret
+
+		BeforeEach(func() {
+			var err error
+			s, err = mgr.Add(file, "")
+			Expect(err).To(BeNil())
+		})
+
+		It("should report nothing when the secret is decrypted", func() {
+			Expect(mgr.Ensure(*s)).To(Succeed())
+			Expect(mgr.Status(*s)).To(BeNil())
+		})
+
+		It("should report when the decrypted file can be read by others", func() {
+			d := mgr.Status(*s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("read by others"))
+		})
+
+		It("should report when the decrypted file is missing", func() {
+			Expect(snapshot.Fs.Remove(file)).To(Succeed())
+
+			d := mgr.Status(*s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("missing"))
+		})
+
+		It("should report when the decrypted file differs", func() {
+			Expect(snapshot.Save("edited", file)).To(Succeed())
+
+			d := mgr.Status(*s)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("differs"))
+		})
+	})
+})
@@ -3,11 +3,14 @@ package symlink
 import (
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-billy.v4/util"
 
 	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/fs"
 	"github.com/mbark/punkt/pkg/printer"
 )
@@ -15,6 +18,23 @@ import (
 var (
 	// ErrNonHomeRelativeTarget is returned if a target has no given link and is outside of the user's home directory
 	ErrNonHomeRelativeTarget = errors.New("non-home relative target given without specific link location")
+	// ErrConflict is returned if something is in the way of creating the symlink and no strategy is given
+	ErrConflict = errors.New("a file already exists where the symlink should be created")
+	// ErrUnknownConflictStrategy is returned if the given conflict strategy isn't one of the known ones
+	ErrUnknownConflictStrategy = errors.New("unknown conflict strategy")
+)
+
+// The strategies available to handle when there is already a file at the
+// location of the symlink and at its target.
+const (
+	// ConflictSkip leaves the existing file and doesn't create the symlink
+	ConflictSkip = "skip"
+	// ConflictBackup moves the existing file to the backup directory
+	ConflictBackup = "backup"
+	// ConflictOverwrite removes the existing file
+	ConflictOverwrite = "overwrite"
+	// ConflictAdopt replaces the target with the existing file
+	ConflictAdopt = "adopt"
 )
 
 // LinkManager ...
@@ -22,6 +42,8 @@ type LinkManager interface {
 	New(target, link string) *Symlink
 	Remove(string) (*Symlink, error)
 	Ensure(symlink *Symlink) error
+	Status(symlink *Symlink) *drift.Drift
+	Pull(symlink *Symlink) error
 	Unexpand(symlink Symlink) *Symlink
 	Expand(symlink Symlink) *Symlink
 }
@@ -29,6 +51,8 @@ type LinkManager interface {
 type symlinkManager struct {
 	snapshot fs.Snapshot
 	config   conf.Config
+	backups  string
+	copies   string
 }
 
 // NewLinkManager ...
@@ -36,6 +60,8 @@ func NewLinkManager(config conf.Config, snapshot fs.Snapshot) LinkManager {
 	return symlinkManager{
 		snapshot: snapshot,
 		config:   config,
+		backups:  filepath.Join(config.PunktHome, "backups", time.Now().Format("20060102-150405.000000000")),
+		copies:   filepath.Join(config.PunktHome, "copies.toml"),
 	}
 }
 
@@ -92,8 +118,16 @@ func (mgr symlinkManager) Remove(link string) (*Symlink, error) {
 //
 // If the given symlink has an existing file at link but not target this
 // will be treated as a file to add, meaning the file at link will be moved
-// to the target path before creating the symlink from link to target.
+// to the target path before creating the symlink from link to target. If
+// both exist the conflict is resolved using the symlink's conflict strategy,
+// or the configured one if it has none.
+//
+// Symlinks in copy mode get a copy of the target at link instead.
 func (mgr symlinkManager) Ensure(symlink *Symlink) error {
+	if symlink.Copy {
+		return mgr.ensureCopy(symlink)
+	}
+
 	logger := logrus.WithFields(logrus.Fields{
 		"link":   symlink.Link,
 		"target": symlink.Target,
@@ -132,6 +166,13 @@ func (mgr symlinkManager) Ensure(symlink *Symlink) error {
 			logger.WithError(err).Error("unable to move link to target location")
 			return errors.Wrapf(err, "failed to rename %s to %s", symlink.Link, symlink.Target)
 		}
+	} else if _, err := mgr.snapshot.Fs.Lstat(symlink.Link); err == nil {
+		logger.Debug("something exists at link, resolving conflict")
+
+		skip, err := mgr.resolveConflict(symlink)
+		if err != nil || skip {
+			return err
+		}
 	}
 
 	err := mgr.snapshot.CreateNecessaryDirectories(symlink.Link)
@@ -144,6 +185,77 @@ func (mgr symlinkManager) Ensure(symlink *Symlink) error {
 	return mgr.snapshot.Fs.Symlink(symlink.Target, symlink.Link)
 }
 
+// resolveConflict handles the file at link according to the conflict
+// strategy, returning true if the symlink should not be created.
+func (mgr symlinkManager) resolveConflict(symlink *Symlink) (bool, error) {
+	strategy := symlink.Conflict
+	if strategy == "" {
+		strategy = mgr.config.Conflict
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"link":     symlink.Link,
+		"target":   symlink.Target,
+		"strategy": strategy,
+	})
+	logger.Info("resolving conflict")
+
+	link := mgr.snapshot.UnexpandHome(symlink.Link)
+	switch strategy {
+	case "":
+		printer.Log.Error("conflict at <fg 1>%s<reset>, choose how to resolve it with --conflict", link)
+		return false, errors.Wrapf(ErrConflict, "unable to create symlink at %s", symlink.Link)
+
+	case ConflictSkip:
+		printer.Log.Warning("conflict at <fg 3>%s<reset>: skipped, the existing file was left as is", link)
+		return true, nil
+
+	case ConflictBackup:
+		backup := filepath.Join(mgr.backups, strings.TrimPrefix(symlink.Link, mgr.snapshot.UserHome))
+		err := mgr.snapshot.CreateNecessaryDirectories(backup)
+		if err != nil {
+			return false, err
+		}
+
+		err = mgr.snapshot.Fs.Rename(symlink.Link, backup)
+		if err != nil {
+			logger.WithError(err).Error("unable to back up existing file")
+			return false, errors.Wrapf(err, "failed to back up %s to %s", symlink.Link, backup)
+		}
+
+		printer.Log.Warning("conflict at <fg 3>%s<reset>: backed up the existing file to <fg 5>%s", link, mgr.snapshot.UnexpandHome(backup))
+		return false, nil
+
+	case ConflictOverwrite:
+		err := util.RemoveAll(mgr.snapshot.Fs, symlink.Link)
+		if err != nil {
+			logger.WithError(err).Error("unable to remove existing file")
+			return false, errors.Wrapf(err, "failed to remove %s", symlink.Link)
+		}
+
+		printer.Log.Warning("conflict at <fg 3>%s<reset>: removed the existing file", link)
+		return false, nil
+
+	case ConflictAdopt:
+		err := util.RemoveAll(mgr.snapshot.Fs, symlink.Target)
+		if err != nil {
+			logger.WithError(err).Error("unable to remove target")
+			return false, errors.Wrapf(err, "failed to remove %s", symlink.Target)
+		}
+
+		err = mgr.snapshot.Fs.Rename(symlink.Link, symlink.Target)
+		if err != nil {
+			logger.WithError(err).Error("unable to move link to target location")
+			return false, errors.Wrapf(err, "failed to rename %s to %s", symlink.Link, symlink.Target)
+		}
+
+		printer.Log.Warning("conflict at <fg 3>%s<reset>: adopted the existing file as <fg 5>%s", link, mgr.snapshot.UnexpandHome(symlink.Target))
+		return false, nil
+	}
+
+	return false, errors.Wrapf(ErrUnknownConflictStrategy, "%s", strategy)
+}
+
 // exists returns true if there exists a symlink at Link pointing to Target
 func (mgr symlinkManager) exists(symlink *Symlink) bool {
 	logger := logrus.WithFields(logrus.Fields{
@@ -161,20 +273,52 @@ func (mgr symlinkManager) exists(symlink *Symlink) bool {
 	return path == symlink.Target
 }
 
+// Status checks if the symlink exists and points to an existing target,
+// returning what differs if it doesn't or nil if the symlink is as expected.
+func (mgr symlinkManager) Status(symlink *Symlink) *drift.Drift {
+	if symlink.Copy {
+		return mgr.statusCopy(symlink)
+	}
+
+	item := mgr.Unexpand(*symlink).Link
+
+	if !mgr.exists(symlink) {
+		if _, err := mgr.snapshot.Fs.Lstat(symlink.Link); err != nil {
+			d := drift.New(item, "symlink is missing")
+			return &d
+		}
+
+		path, err := mgr.snapshot.Fs.Readlink(symlink.Link)
+		if err != nil {
+			d := drift.New(item, "file exists but is not a symlink")
+			return &d
+		}
+
+		d := drift.New(item, "points to %s instead of %s",
+			mgr.snapshot.UnexpandHome(path), mgr.snapshot.UnexpandHome(symlink.Target))
+		return &d
+	}
+
+	if _, err := mgr.snapshot.Fs.Stat(symlink.Target); err != nil {
+		d := drift.New(item, "symlink is broken, %s does not exist", mgr.snapshot.UnexpandHome(symlink.Target))
+		return &d
+	}
+
+	return nil
+}
+
 // Expand ...
 func (mgr symlinkManager) Expand(symlink Symlink) *Symlink {
-	return &Symlink{
-		Target: mgr.snapshot.ExpandHome(symlink.Target),
-		Link:   mgr.snapshot.ExpandHome(symlink.Link),
-	}
+	symlink.Target = mgr.snapshot.ExpandHome(symlink.Target)
+	symlink.Link = mgr.snapshot.ExpandHome(symlink.Link)
+	return &symlink
 }
 
 // Unexpand ...
 func (mgr symlinkManager) Unexpand(symlink Symlink) *Symlink {
-	return &Symlink{
-		Target: mgr.snapshot.UnexpandHome(symlink.Target),
-		Link:   mgr.snapshot.UnexpandHome(symlink.Link),
-	}
+	symlink.Target = mgr.snapshot.UnexpandHome(symlink.Target)
+	symlink.Link = mgr.snapshot.UnexpandHome(symlink.Link)
+	return &symlink
 }
 
 func deriveLink(target, targetDir, linkDir string) (string, error) {
@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
 
 	"github.com/mbark/punkt/pkg/conf"
 	"github.com/mbark/punkt/pkg/fs"
@@ -152,17 +153,148 @@ var _ = Describe("Symlink: Link Manager", func() {
 			Expect(mgr.Ensure(&symlink.Symlink{Target: target, Link: link})).NotTo(Succeed())
 		})
 
+		Context("when both the link and target exist", func() {
+			var link, target string
+
+			BeforeEach(func() {
+				link = filepath.Join(snapshot.UserHome, "target")
+				target = filepath.Join(config.Dotfiles, "target")
+				Expect(snapshot.Save("link", link)).To(Succeed())
+				Expect(snapshot.Save("target", target)).To(Succeed())
+			})
+
+			It("should leave the existing file when skipping", func() {
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: symlink.ConflictSkip}
+				Expect(mgr.Ensure(s)).To(Succeed())
+
+				content, err := snapshot.Read(link)
+				Expect(err).To(BeNil())
+				Expect(content).To(Equal("link"))
+			})
+
+			It("should back up the existing file into punkt home", func() {
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: symlink.ConflictBackup}
+				Expect(mgr.Ensure(s)).To(Succeed())
+
+				actual, err := snapshot.Fs.Readlink(link)
+				Expect(err).To(BeNil())
+				Expect(actual).To(Equal(target))
+
+				backups, err := util.Glob(snapshot.Fs, filepath.Join(config.PunktHome, "backups", "*", "target"))
+				Expect(err).To(BeNil())
+				Expect(backups).To(HaveLen(1))
+
+				content, err := snapshot.Read(backups[0])
+				Expect(err).To(BeNil())
+				Expect(content).To(Equal("link"))
+			})
+
+			It("should remove the existing file when overwriting", func() {
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: symlink.ConflictOverwrite}
+				Expect(mgr.Ensure(s)).To(Succeed())
+
+				content, err := snapshot.Read(link)
+				Expect(err).To(BeNil())
+				Expect(content).To(Equal("target"))
+			})
+
+			It("should replace the target with the existing file when adopting", func() {
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: symlink.ConflictAdopt}
+				Expect(mgr.Ensure(s)).To(Succeed())
+
+				content, err := snapshot.Read(link)
+				Expect(err).To(BeNil())
+				Expect(content).To(Equal("link"))
+
+				actual, err := snapshot.Fs.Readlink(link)
+				Expect(err).To(BeNil())
+				Expect(actual).To(Equal(target))
+			})
+
+			It("should use the configured strategy if the symlink has none", func() {
+				config.Conflict = symlink.ConflictOverwrite
+				mgr = symlink.NewLinkManager(*config, snapshot)
+
+				Expect(mgr.Ensure(&symlink.Symlink{Target: target, Link: link})).To(Succeed())
+
+				actual, err := snapshot.Fs.Readlink(link)
+				Expect(err).To(BeNil())
+				Expect(actual).To(Equal(target))
+			})
+
+			It("should prefer the symlink's strategy over the configured one", func() {
+				config.Conflict = symlink.ConflictOverwrite
+				mgr = symlink.NewLinkManager(*config, snapshot)
+
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: symlink.ConflictSkip}
+				Expect(mgr.Ensure(s)).To(Succeed())
+
+				_, err := snapshot.Fs.Readlink(link)
+				Expect(err).NotTo(BeNil())
+			})
+
+			It("should fail for unknown strategies", func() {
+				s := &symlink.Symlink{Target: target, Link: link, Conflict: "unknown"}
+				Expect(mgr.Ensure(s)).NotTo(Succeed())
+			})
+		})
+
 		It("should succeed even if neither of the two files exist", func() {
 			link := &symlink.Symlink{Target: "/target", Link: "/link"}
 			Expect(mgr.Ensure(link)).To(Succeed())
 		})
 	})
 
+	var _ = Context("Status", func() {
+		var target, link string
+
+		BeforeEach(func() {
+			target = filepath.Join(config.Dotfiles, "target")
+			link = filepath.Join(snapshot.UserHome, "target")
+		})
+
+		It("should report nothing if the symlink exists", func() {
+			_, err := snapshot.Fs.Create(target)
+			Expect(err).To(BeNil())
+			Expect(snapshot.Fs.Symlink(target, link)).To(Succeed())
+
+			Expect(mgr.Status(&symlink.Symlink{Target: target, Link: link})).To(BeNil())
+		})
+
+		It("should report a missing symlink", func() {
+			Expect(mgr.Status(&symlink.Symlink{Target: target, Link: link})).NotTo(BeNil())
+		})
+
+		It("should report a broken symlink", func() {
+			Expect(snapshot.Fs.Symlink(target, link)).To(Succeed())
+
+			Expect(mgr.Status(&symlink.Symlink{Target: target, Link: link})).NotTo(BeNil())
+		})
+
+		It("should report a symlink pointing somewhere else", func() {
+			_, err := snapshot.Fs.Create("/other")
+			Expect(err).To(BeNil())
+			Expect(snapshot.Fs.Symlink("/other", link)).To(Succeed())
+
+			d := mgr.Status(&symlink.Symlink{Target: target, Link: link})
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("/other"))
+		})
+
+		It("should report a file that isn't a symlink", func() {
+			_, err := snapshot.Fs.Create(link)
+			Expect(err).To(BeNil())
+
+			Expect(mgr.Status(&symlink.Symlink{Target: target, Link: link})).NotTo(BeNil())
+		})
+	})
+
 	var _ = Describe("Unexpand", func() {
 		It("should expand tilde to the home directory", func() {
-			s := mgr.Expand(symlink.Symlink{Target: "~/target", Link: "~/link"})
+			s := mgr.Expand(symlink.Symlink{Target: "~/target", Link: "~/link", Conflict: symlink.ConflictSkip})
 			Expect(s.Target).To(Equal(filepath.Join(snapshot.UserHome, "target")))
 			Expect(s.Link).To(Equal(filepath.Join(snapshot.UserHome, "link")))
+			Expect(s.Conflict).To(Equal(symlink.ConflictSkip))
 		})
 	})
 
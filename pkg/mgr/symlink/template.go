@@ -0,0 +1,279 @@
+package symlink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/drift"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// Template describes a file in the dotfiles that is rendered with the facts
+// of the machine and written to Destination, instead of being symlinked.
+type Template struct {
+	Source      string
+	Destination string
+	// Conflict is the strategy to use if the rendered file has been edited
+	// locally, only backup and overwrite will replace it.
+	Conflict string
+	// Condition limits which machines the template is rendered on
+	machine.Condition
+}
+
+func (t Template) String() string {
+	return fmt.Sprintf("%s <- %s", t.Destination, t.Source)
+}
+
+// TemplateManager renders templates, keeping track of what was rendered to
+// know when it needs to be rendered again.
+type TemplateManager interface {
+	Ensure(t Template) error
+	Status(t Template) *drift.Drift
+}
+
+// rendered is what is stored about a template when it is rendered, the hash
+// of the template and the facts used and the hash of the result.
+type rendered struct {
+	Input  string
+	Output string
+}
+
+type templateManager struct {
+	snapshot  fs.Snapshot
+	config    conf.Config
+	stateFile string
+	backups   string
+	facts     *machine.Facts
+}
+
+// NewTemplateManager ...
+func NewTemplateManager(config conf.Config, snapshot fs.Snapshot) TemplateManager {
+	return &templateManager{
+		snapshot:  snapshot,
+		config:    config,
+		stateFile: filepath.Join(config.PunktHome, "templates.toml"),
+		backups:   filepath.Join(config.PunktHome, "backups", time.Now().Format("20060102-150405.000000000")),
+	}
+}
+
+// Ensure renders the template to its destination if the template or the
+// facts it is rendered with have changed since it was last rendered. If the
+// destination has been edited since then it is left as is, unless the
+// conflict strategy says otherwise.
+func (mgr *templateManager) Ensure(t Template) error {
+	t = mgr.expand(t)
+	logger := logrus.WithFields(logrus.Fields{
+		"source":      t.Source,
+		"destination": t.Destination,
+	})
+
+	out, input, err := mgr.render(t)
+	if err != nil {
+		return err
+	}
+
+	state, err := mgr.readState()
+	if err != nil {
+		return err
+	}
+
+	destination := mgr.snapshot.UnexpandHome(t.Destination)
+	previous, rendered := state[destination]
+	current, err := mgr.snapshot.Read(t.Destination)
+	switch {
+	case err == fs.ErrNoSuchFile:
+		logger.Debug("destination doesn't exist, rendering")
+
+	case err != nil:
+		return err
+
+	case current == out:
+		if !rendered || previous.Input != input {
+			logger.Debug("destination already matches the rendered template")
+			state[destination] = renderedState(input, out)
+			return mgr.snapshot.SaveToml(state, mgr.stateFile)
+		}
+
+		printer.Log.Note("template is up to date: <fg 5>%s", destination)
+		return nil
+
+	case !rendered || hash(current) != previous.Output:
+		logger.Info("destination has been edited since it was rendered")
+		replace, err := mgr.resolveEdit(t)
+		if err != nil || !replace {
+			return err
+		}
+
+	case previous.Input == input:
+		printer.Log.Note("template is up to date: <fg 5>%s", destination)
+		return nil
+	}
+
+	err = mgr.snapshot.Save(out, t.Destination)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", t.Destination)
+	}
+
+	logger.Info("rendered template")
+	printer.Log.Note("rendered template: <fg 2>%s", destination)
+
+	state[destination] = renderedState(input, out)
+	return mgr.snapshot.SaveToml(state, mgr.stateFile)
+}
+
+// resolveEdit handles a destination that has been edited locally, returning
+// true if it should be replaced by the rendered template.
+func (mgr *templateManager) resolveEdit(t Template) (bool, error) {
+	strategy := t.Conflict
+	if strategy == "" {
+		strategy = mgr.config.Conflict
+	}
+
+	return replaceEdited(mgr.snapshot, mgr.backups, t.Destination, strategy, "not rendering the template")
+}
+
+// replaceEdited resolves a file that punkt writes having been edited
+// locally, returning true if it should be replaced. The skipped message is
+// shown when the edit is kept.
+func replaceEdited(snapshot fs.Snapshot, backups, file, strategy, skipped string) (bool, error) {
+	item := snapshot.UnexpandHome(file)
+	switch strategy {
+	case ConflictOverwrite:
+		printer.Log.Warning("<fg 3>%s<reset> was edited locally: overwriting it", item)
+		return true, nil
+
+	case ConflictBackup:
+		backup := filepath.Join(backups, strings.TrimPrefix(file, snapshot.UserHome))
+		err := snapshot.CreateNecessaryDirectories(backup)
+		if err != nil {
+			return false, err
+		}
+
+		err = snapshot.Fs.Rename(file, backup)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to back up %s to %s", file, backup)
+		}
+
+		printer.Log.Warning("<fg 3>%s<reset> was edited locally: backed it up to <fg 5>%s", item, snapshot.UnexpandHome(backup))
+		return true, nil
+
+	case "", ConflictSkip:
+		printer.Log.Warning("<fg 3>%s<reset> was edited locally: %s", item, skipped)
+		return false, nil
+
+	case ConflictAdopt:
+		// the file is generated from its source, the edit can't replace it
+		printer.Log.Warning("<fg 3>%s<reset> was edited locally and can't be adopted: %s", item, skipped)
+		return false, nil
+	}
+
+	return false, errors.Wrapf(ErrUnknownConflictStrategy, "%s", strategy)
+}
+
+// Status reports if the destination is missing, has been edited locally or
+// is out of date with the template.
+func (mgr *templateManager) Status(t Template) *drift.Drift {
+	t = mgr.expand(t)
+	item := mgr.snapshot.UnexpandHome(t.Destination)
+
+	out, input, err := mgr.render(t)
+	if err != nil {
+		d := drift.New(item, "unable to render template: %s", err)
+		return &d
+	}
+
+	current, err := mgr.snapshot.Read(t.Destination)
+	if err != nil {
+		d := drift.New(item, "rendered template is missing")
+		return &d
+	}
+
+	if current == out {
+		return nil
+	}
+
+	state, err := mgr.readState()
+	if err != nil {
+		d := drift.New(item, "unable to read the template state: %s", err)
+		return &d
+	}
+
+	previous, ok := state[item]
+	if !ok || hash(current) != previous.Output {
+		d := drift.New(item, "rendered template has been edited locally")
+		return &d
+	}
+
+	if previous.Input != input {
+		d := drift.New(item, "template or its inputs have changed since it was rendered")
+		return &d
+	}
+
+	return nil
+}
+
+// render the template, returning the result and a hash of what it was
+// rendered from
+func (mgr *templateManager) render(t Template) (string, string, error) {
+	source, err := mgr.snapshot.Read(t.Source)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read template %s", t.Source)
+	}
+
+	if mgr.facts == nil {
+		mgr.facts, err = machine.New(mgr.config.Variables)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	tmpl, err := template.New(filepath.Base(t.Source)).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to parse template %s", t.Source)
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, mgr.facts)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to render template %s", t.Source)
+	}
+
+	return out.String(), hash(source + fmt.Sprintf("%+v", *mgr.facts)), nil
+}
+
+func (mgr *templateManager) readState() (map[string]rendered, error) {
+	state := make(map[string]rendered)
+	err := mgr.snapshot.ReadToml(&state, mgr.stateFile)
+	if err != nil && err != fs.ErrNoSuchFile {
+		return nil, errors.Wrapf(err, "unable to read template state")
+	}
+
+	return state, nil
+}
+
+func (mgr *templateManager) expand(t Template) Template {
+	t.Source = mgr.snapshot.ExpandHome(t.Source)
+	t.Destination = mgr.snapshot.ExpandHome(t.Destination)
+	return t
+}
+
+func renderedState(input, out string) rendered {
+	return rendered{Input: input, Output: hash(out)}
+}
+
+func hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+package symlink_test
+
+import (
+	"os/user"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/machine"
+	"github.com/mbark/punkt/pkg/mgr/symlink"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Symlink: Template Manager", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var mgr symlink.TemplateManager
+	var tmpl symlink.Template
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		config.Variables = map[string]string{"email": "me@example.com"}
+
+		machine.Hostname = func() (string, error) { return "laptop", nil }
+		machine.CurrentUser = func() (*user.User, error) {
+			return &user.User{Username: "me", HomeDir: snapshot.UserHome}, nil
+		}
+
+		tmpl = symlink.Template{
+			Source:      filepath.Join(config.Dotfiles, "gitconfig.tmpl"),
+			Destination: filepath.Join(snapshot.UserHome, ".gitconfig"),
+		}
+		Expect(snapshot.Save("{{ .User }}@{{ .Hostname }} {{ .Variables.email }}", tmpl.Source)).To(Succeed())
+
+		mgr = symlink.NewTemplateManager(config, snapshot)
+	})
+
+	read := func() string {
+		content, err := snapshot.Read(tmpl.Destination)
+		Expect(err).To(BeNil())
+		return content
+	}
+
+	Context("Ensure", func() {
+		It("should render the template with the machine's facts", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("me@laptop me@example.com"))
+		})
+
+		It("should handle home relative paths", func() {
+			Expect(mgr.Ensure(symlink.Template{
+				Source:      "~/.dotfiles/gitconfig.tmpl",
+				Destination: "~/.gitconfig",
+			})).To(Succeed())
+			Expect(read()).To(Equal("me@laptop me@example.com"))
+		})
+
+		It("should re-render when the template changes", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("{{ .OS }}", tmpl.Source)).To(Succeed())
+
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal(machine.OS))
+		})
+
+		It("should re-render when the variables change", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+
+			config.Variables["email"] = "other@example.com"
+			Expect(symlink.NewTemplateManager(config, snapshot).Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("me@laptop other@example.com"))
+		})
+
+		It("should not overwrite a file that was edited locally", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("edited", tmpl.Destination)).To(Succeed())
+			Expect(snapshot.Save("{{ .OS }}", tmpl.Source)).To(Succeed())
+
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("edited"))
+		})
+
+		It("should not overwrite an existing file it didn't render", func() {
+			Expect(snapshot.Save("existing", tmpl.Destination)).To(Succeed())
+
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("existing"))
+		})
+
+		It("should overwrite a file edited locally when told to", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("edited", tmpl.Destination)).To(Succeed())
+
+			tmpl.Conflict = symlink.ConflictOverwrite
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("me@laptop me@example.com"))
+		})
+
+		It("should back up a file edited locally when told to", func() {
+			config.Conflict = symlink.ConflictBackup
+			mgr = symlink.NewTemplateManager(config, snapshot)
+			Expect(snapshot.Save("edited", tmpl.Destination)).To(Succeed())
+
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("me@laptop me@example.com"))
+
+			backups, err := snapshot.Fs.ReadDir(filepath.Join(config.PunktHome, "backups"))
+			Expect(err).To(BeNil())
+			Expect(backups).To(HaveLen(1))
+		})
+
+		It("should keep a file edited locally when told to adopt it", func() {
+			config.Conflict = symlink.ConflictAdopt
+			mgr = symlink.NewTemplateManager(config, snapshot)
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("edited", tmpl.Destination)).To(Succeed())
+
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(read()).To(Equal("edited"))
+			Expect(snapshot.Read(tmpl.Source)).NotTo(Equal("edited"))
+		})
+
+		It("should fail if a variable is missing", func() {
+			Expect(snapshot.Save("{{ .Variables.missing }}", tmpl.Source)).To(Succeed())
+			Expect(mgr.Ensure(tmpl)).NotTo(Succeed())
+		})
+
+		It("should fail if the template doesn't exist", func() {
+			tmpl.Source = "/non/existant"
+			Expect(mgr.Ensure(tmpl)).NotTo(Succeed())
+		})
+	})
+
+	Context("Status", func() {
+		It("should report nothing when the template is rendered", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(mgr.Status(tmpl)).To(BeNil())
+		})
+
+		It("should report when the rendered file is missing", func() {
+			d := mgr.Status(tmpl)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("missing"))
+		})
+
+		It("should report when the rendered file was edited locally", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("edited", tmpl.Destination)).To(Succeed())
+
+			d := mgr.Status(tmpl)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("edited locally"))
+		})
+
+		It("should report when the template has changed", func() {
+			Expect(mgr.Ensure(tmpl)).To(Succeed())
+			Expect(snapshot.Save("{{ .OS }}", tmpl.Source)).To(Succeed())
+
+			d := mgr.Status(tmpl)
+			Expect(d).NotTo(BeNil())
+			Expect(d.Reason).To(ContainSubstring("changed"))
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package mgr
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// Sync commits the changes in the dotfiles, with a message describing the
+// punkt operation that made them, and syncs the dotfiles with their remote.
+// Of what's in punkt home only the configuration files are committed, the
+// state punkt keeps there is left out.
+func (rootMgr RootManager) Sync(operation string) error {
+	dotfiles := rootMgr.snapshot.UnexpandHome(rootMgr.config.Dotfiles)
+	printer.Log.Start("sync", "dotfiles: <fg 2>%s", dotfiles)
+
+	if !rootMgr.inDotfiles(rootMgr.config.PunktHome) {
+		printer.Log.Note("punkt home <fg 5>%s<reset> is outside of your dotfiles, only what's linked into them is synced",
+			rootMgr.snapshot.UnexpandHome(rootMgr.config.PunktHome))
+	}
+
+	repoMgr := rootMgr.Git().RepoManager
+	message := "punkt " + operation
+	committed, err := repoMgr.Commit(rootMgr.config.Dotfiles, message, rootMgr.syncs())
+	if err != nil {
+		printer.Log.Error("failed to commit the changes: <fg 1>%s", err)
+		return errors.Wrapf(err, "unable to commit %s", rootMgr.config.Dotfiles)
+	}
+
+	if committed {
+		printer.Log.Success("committed: <fg 2>%s", message)
+	} else {
+		printer.Log.Note("no changes to commit")
+	}
+
+	err = repoMgr.Sync(rootMgr.config.Dotfiles)
+	switch errors.Cause(err) {
+	case nil:
+		printer.Log.Done("sync", "dotfiles are in sync with the remote")
+		return nil
+	case git.ErrNoRemote:
+		printer.Log.Warning("<fg 3>%s<reset> has no remote to sync with", dotfiles)
+		return nil
+	case git.ErrDiverged:
+		printer.Log.Error("<fg 1>%s<reset> and its remote have conflicting changes, resolve the conflict with git and sync again", dotfiles)
+		return err
+	default:
+		printer.Log.Error("failed to sync with the remote: <fg 1>%s", err)
+		return err
+	}
+}
+
+// syncs returns a filter accepting the files, relative to the dotfiles,
+// that should be committed, the configuration files of the managers that
+// are configured or found as plugins are included without running them.
+func (rootMgr RootManager) syncs() func(file string) bool {
+	configs := map[string]bool{
+		filepath.Join(rootMgr.config.PunktHome, "config.toml"):   true,
+		filepath.Join(rootMgr.config.PunktHome, "managers.toml"): true,
+		rootMgr.ConfigFile("git"):                                true,
+		rootMgr.ConfigFile("symlink"):                            true,
+	}
+	for name := range rootMgr.config.Managers {
+		configs[rootMgr.ConfigFile(name)] = true
+	}
+	for name := range rootMgr.discover() {
+		configs[rootMgr.ConfigFile(name)] = true
+	}
+
+	return func(file string) bool {
+		abs := filepath.Join(rootMgr.config.Dotfiles, file)
+		if !strings.HasPrefix(abs, rootMgr.config.PunktHome+string(filepath.Separator)) {
+			return true
+		}
+
+		return configs[abs]
+	}
+}
+
+// inDotfiles returns true if the path is within the dotfiles directory
+func (rootMgr RootManager) inDotfiles(path string) bool {
+	return strings.HasPrefix(path, rootMgr.config.Dotfiles+string(filepath.Separator))
+}
@@ -0,0 +1,106 @@
+package mgr_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	goGit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	"github.com/mbark/punkt/pkg/conf"
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/mgr"
+	"github.com/mbark/punkt/pkg/mgr/git"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/testmock"
+)
+
+var _ = Describe("Manager: Sync", func() {
+	var snapshot fs.Snapshot
+	var config conf.Config
+	var root *mgr.RootManager
+
+	// head returns the files in the dotfiles' last commit, including those in
+	// punkt home, and its message
+	head := func() ([]string, string) {
+		worktree, err := snapshot.Fs.Chroot(config.Dotfiles)
+		Expect(err).To(BeNil())
+		dotGit, err := worktree.Chroot(".git")
+		Expect(err).To(BeNil())
+		storage, err := filesystem.NewStorage(dotGit)
+		Expect(err).To(BeNil())
+		repo, err := goGit.Open(storage, worktree)
+		Expect(err).To(BeNil())
+
+		ref, err := repo.Head()
+		Expect(err).To(BeNil())
+		commit, err := repo.CommitObject(ref.Hash())
+		Expect(err).To(BeNil())
+		tree, err := commit.Tree()
+		Expect(err).To(BeNil())
+
+		var files []string
+		for _, entry := range tree.Entries {
+			files = append(files, entry.Name)
+		}
+		if punkt, err := tree.Tree("punkt"); err == nil {
+			for _, entry := range punkt.Entries {
+				files = append(files, filepath.Join("punkt", entry.Name))
+			}
+		}
+
+		return files, commit.Message
+	}
+
+	BeforeEach(func() {
+		snapshot, config = testmock.Setup()
+		config.PunktHome = filepath.Join(config.Dotfiles, "punkt")
+		root = mgr.NewRootManager(config, snapshot)
+
+		Expect(git.NewRepoManager(snapshot.Fs).Init(config.Dotfiles)).To(Succeed())
+		Expect(snapshot.Save("vim", filepath.Join(config.Dotfiles, ".vimrc"))).To(Succeed())
+		Expect(snapshot.Save("links", root.ConfigFile("symlink"))).To(Succeed())
+		Expect(snapshot.Save("state", filepath.Join(config.PunktHome, "copies.toml"))).To(Succeed())
+	})
+
+	It("should commit the dotfiles and punkt's configuration", func() {
+		Expect(root.Sync("add symlink ~/.vimrc")).To(Succeed())
+
+		files, message := head()
+		Expect(message).To(Equal("punkt add symlink ~/.vimrc"))
+		Expect(files).To(ConsistOf(".vimrc", "punkt", "punkt/symlink.toml"))
+	})
+
+	It("should commit the configuration of the configured managers", func() {
+		config.Managers = map[string]map[string]string{"pip": {"plugin": "/does/not/exist"}}
+		root = mgr.NewRootManager(config, snapshot)
+		Expect(snapshot.Save("requests", root.ConfigFile("pip"))).To(Succeed())
+
+		Expect(root.Sync("dump")).To(Succeed())
+
+		files, _ := head()
+		Expect(files).To(ConsistOf(".vimrc", "punkt", "punkt/symlink.toml", "punkt/pip.toml"))
+	})
+
+	It("should succeed with nothing to commit", func() {
+		Expect(root.Sync("sync")).To(Succeed())
+		Expect(root.Sync("sync")).To(Succeed())
+	})
+
+	It("should only plan the commit and sync when doing a dry run", func() {
+		root.Plan = plan.New()
+
+		Expect(root.Sync("sync")).To(Succeed())
+		Expect(root.Plan.Operations).To(ConsistOf(
+			plan.Operation{Kind: "commit", Args: []string{config.Dotfiles, "punkt sync"}},
+			plan.Operation{Kind: "pull", Args: []string{config.Dotfiles}},
+			plan.Operation{Kind: "push", Args: []string{config.Dotfiles}},
+		))
+	})
+
+	It("should fail if the dotfiles aren't a repository", func() {
+		Expect(snapshot.Fs.Rename(filepath.Join(config.Dotfiles, ".git"), "/git")).To(Succeed())
+		Expect(root.Sync("sync")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,81 @@
+package plan
+
+import (
+	"os"
+
+	billy "gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/helper/chroot"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+// filesystem wraps a billy.Filesystem, reading from it as usual but
+// recording anything that would modify it instead of doing so.
+type filesystem struct {
+	billy.Filesystem
+	plan    *Plan
+	discard billy.Filesystem
+}
+
+// NewFilesystem returns a filesystem that records all modifications in the
+// plan without performing them, reads are passed through to the given
+// filesystem.
+func NewFilesystem(p *Plan, fs billy.Filesystem) billy.Filesystem {
+	return &filesystem{
+		Filesystem: fs,
+		plan:       p,
+		discard:    memfs.New(),
+	}
+}
+
+func (fs *filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&writeFlags == 0 {
+		return fs.Filesystem.OpenFile(filename, flag, perm)
+	}
+
+	fs.plan.Record("write", filename)
+
+	// managers run at the same time share the discarding filesystem
+	fs.plan.mutex.Lock()
+	defer fs.plan.mutex.Unlock()
+	return fs.discard.Create(filename)
+}
+
+func (fs *filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	fs.plan.mutex.Lock()
+	defer fs.plan.mutex.Unlock()
+	return fs.discard.TempFile(dir, prefix)
+}
+
+func (fs *filesystem) Rename(from, to string) error {
+	fs.plan.Record("move", from, "->", to)
+	return nil
+}
+
+func (fs *filesystem) Remove(filename string) error {
+	fs.plan.Record("remove", filename)
+	return nil
+}
+
+func (fs *filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	if _, err := fs.Filesystem.Stat(filename); err == nil {
+		return nil
+	}
+
+	fs.plan.Record("create directory", filename)
+	return nil
+}
+
+func (fs *filesystem) Symlink(target, link string) error {
+	fs.plan.Record("symlink", link, "->", target)
+	return nil
+}
+
+func (fs *filesystem) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, fs.Join(fs.Root(), path)), nil
+}
@@ -0,0 +1,71 @@
+package plan
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+// Operation describes a single change that would be made to the system
+type Operation struct {
+	Kind string
+	Args []string
+}
+
+func (op Operation) String() string {
+	return fmt.Sprintf("%s %s", op.Kind, strings.Join(op.Args, " "))
+}
+
+// Plan records the operations that would be performed instead of
+// performing them, used to do a dry run.
+type Plan struct {
+	mutex      *sync.Mutex
+	Operations []Operation
+}
+
+// New ...
+func New() *Plan {
+	return &Plan{mutex: new(sync.Mutex)}
+}
+
+// Record the operation, keeping the order operations were recorded in
+func (p *Plan) Record(kind string, args ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"kind": kind,
+		"args": args,
+	}).Debug("recording planned operation")
+	p.Operations = append(p.Operations, Operation{Kind: kind, Args: args})
+}
+
+// Commander can be used in place of run.Commander, it records the command
+// and returns a command that does nothing.
+func (p *Plan) Commander(command string, args ...string) *exec.Cmd {
+	p.Record("run", append([]string{command}, args...)...)
+	return exec.Command("true")
+}
+
+// Print the planned operations in the order they were recorded
+func (p *Plan) Print() {
+	if len(p.Operations) == 0 {
+		printer.Log.Note("dry run, nothing would be changed")
+		return
+	}
+
+	printer.Log.Note("dry run, the following operations would be performed:")
+	for i, op := range p.Operations {
+		printer.Log.Progress(i, len(p.Operations), "<fg 5>%s<reset> %s", op.Kind, strings.Join(op.Args, " "))
+		printer.Log.Event(printer.Event{
+			Operation: op.Kind,
+			Item:      strings.Join(op.Args, " "),
+			Result:    printer.ResultPlanned,
+		})
+	}
+}
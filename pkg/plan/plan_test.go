@@ -0,0 +1,102 @@
+package plan_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	billy "gopkg.in/src-d/go-billy.v4"
+
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/plan"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestPlan(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Plan Suite")
+}
+
+var _ = Describe("Plan", func() {
+	var snapshot fs.Snapshot
+	var p *plan.Plan
+	var planned billy.Filesystem
+
+	BeforeEach(func() {
+		snapshot, _ = testmock.Setup()
+		p = plan.New()
+		planned = plan.NewFilesystem(p, snapshot.Fs)
+	})
+
+	It("should keep the operations in the order they were recorded", func() {
+		p.Record("first")
+		p.Record("second", "arg")
+
+		Expect(p.Operations).To(Equal([]plan.Operation{
+			{Kind: "first"},
+			{Kind: "second", Args: []string{"arg"}},
+		}))
+	})
+
+	It("should record commands instead of running them", func() {
+		cmd := p.Commander("rm", "-rf", "/")
+
+		Expect(cmd.Run()).To(Succeed())
+		Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "run", Args: []string{"rm", "-rf", "/"}}))
+	})
+
+	Context("Filesystem", func() {
+		It("should read from the underlying filesystem", func() {
+			Expect(snapshot.Save("content", "/file")).To(Succeed())
+
+			_, err := planned.Stat("/file")
+			Expect(err).To(BeNil())
+			Expect(p.Operations).To(BeEmpty())
+		})
+
+		It("should record writes without performing them", func() {
+			f, err := planned.Create("/file")
+			Expect(err).To(BeNil())
+			_, err = f.Write([]byte("content"))
+			Expect(err).To(BeNil())
+
+			_, err = snapshot.Fs.Stat("/file")
+			Expect(err).NotTo(BeNil())
+			Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "write", Args: []string{"/file"}}))
+		})
+
+		It("should record moves, removals and symlinks without performing them", func() {
+			Expect(snapshot.Save("content", "/file")).To(Succeed())
+
+			Expect(planned.Rename("/file", "/moved")).To(Succeed())
+			Expect(planned.Symlink("/file", "/link")).To(Succeed())
+			Expect(planned.Remove("/file")).To(Succeed())
+
+			_, err := snapshot.Fs.Stat("/file")
+			Expect(err).To(BeNil())
+			_, err = snapshot.Fs.Lstat("/link")
+			Expect(err).NotTo(BeNil())
+			Expect(p.Operations).To(HaveLen(3))
+		})
+
+		It("should only record directories that don't already exist", func() {
+			Expect(snapshot.Fs.MkdirAll("/exists", os.ModePerm)).To(Succeed())
+
+			Expect(planned.MkdirAll("/exists", os.ModePerm)).To(Succeed())
+			Expect(planned.MkdirAll("/missing", os.ModePerm)).To(Succeed())
+
+			Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "create directory", Args: []string{"/missing"}}))
+		})
+
+		It("should keep recording when chrooted", func() {
+			chrooted, err := planned.Chroot("/dir")
+			Expect(err).To(BeNil())
+
+			_, err = chrooted.Create("file")
+			Expect(err).To(BeNil())
+
+			Expect(p.Operations).To(ConsistOf(plan.Operation{Kind: "write", Args: []string{"/dir/file"}}))
+		})
+	})
+})
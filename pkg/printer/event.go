@@ -0,0 +1,136 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// The formats that output can be printed in
+const (
+	// FormatText prints colorized text for the user, it is the default
+	FormatText = "text"
+	// FormatJSON prints all events as a JSON array once the command finishes
+	FormatJSON = "json"
+	// FormatJSONL prints each event as a JSON object on its own line as soon
+	// as it happens
+	FormatJSONL = "jsonl"
+)
+
+// The results an event can have
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+	ResultSkipped = "skipped"
+	ResultDrift   = "drift"
+	ResultPlanned = "planned"
+)
+
+// Event is a structured record of something punkt did, printed when the
+// output format is json or jsonl.
+type Event struct {
+	// Manager is the name of the manager the event is for, if any
+	Manager   string `json:"manager,omitempty"`
+	Operation string `json:"operation"`
+	// Item is what the operation was for, e.g. a symlink
+	Item    string `json:"item,omitempty"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Errors are the individual errors when the operation failed with
+	// several
+	Errors []string `json:"errors,omitempty"`
+	// Duration is how long the operation took in nanoseconds
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// NewEvent creates the event for an operation started at the given time,
+// its result is an error if err is set and a success otherwise.
+func NewEvent(manager, operation, item string, started time.Time, err error) Event {
+	event := Event{
+		Manager:   manager,
+		Operation: operation,
+		Item:      item,
+		Result:    ResultSuccess,
+		Duration:  time.Since(started),
+	}
+
+	if err != nil {
+		event.Result = ResultError
+		event.Error = err.Error()
+		if merr, ok := errors.Cause(err).(*multierror.Error); ok {
+			for _, e := range merr.Errors {
+				event.Errors = append(event.Errors, e.Error())
+			}
+		}
+	}
+
+	return event
+}
+
+// SetFormat sets the format output is printed in. In the json formats
+// events are printed to stdout and the text meant for the user goes to
+// stderr instead.
+func (logger *Logger) SetFormat(format string) error {
+	switch format {
+	case FormatText:
+	case FormatJSON, FormatJSONL:
+		logger.Out = os.Stderr
+	default:
+		return errors.Errorf("unknown output format %s, expected %s, %s or %s", format, FormatText, FormatJSON, FormatJSONL)
+	}
+
+	logger.format = format
+	return nil
+}
+
+// Structured returns true if events are printed
+func (logger Logger) Structured() bool {
+	return logger.format == FormatJSON || logger.format == FormatJSONL
+}
+
+// Event prints the event, or keeps it to be printed by Flush, depending on
+// the format. Nothing is printed for text output.
+func (logger *Logger) Event(event Event) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	switch logger.format {
+	case FormatJSON:
+		logger.events = append(logger.events, event)
+	case FormatJSONL:
+		writeJSON(logger.EventsOut, event)
+	}
+}
+
+// Flush prints the events kept for json output as an array
+func (logger *Logger) Flush() {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if logger.format != FormatJSON {
+		return
+	}
+
+	events := logger.events
+	if events == nil {
+		events = []Event{}
+	}
+
+	writeJSON(logger.EventsOut, events)
+	logger.events = nil
+}
+
+func writeJSON(out io.Writer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(Event{Result: ResultError, Error: err.Error()})
+	}
+
+	fmt.Fprintln(out, string(b))
+}
@@ -0,0 +1,99 @@
+package printer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/printer"
+)
+
+func TestPrinter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Printer Suite")
+}
+
+var _ = Describe("Printer: Event", func() {
+	var logger *printer.Logger
+	var out, events *bytes.Buffer
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		events = new(bytes.Buffer)
+		logger = printer.New()
+	})
+
+	use := func(format string) {
+		Expect(logger.SetFormat(format)).To(Succeed())
+		logger.Out = out
+		logger.EventsOut = events
+	}
+
+	It("should create a successful event without an error", func() {
+		event := printer.NewEvent("brew", "ensure", "", time.Now(), nil)
+		Expect(event.Result).To(Equal(printer.ResultSuccess))
+		Expect(event.Error).To(BeEmpty())
+	})
+
+	It("should keep each error of a multierror", func() {
+		var err error
+		err = multierror.Append(err, fmt.Errorf("first"), fmt.Errorf("second"))
+
+		event := printer.NewEvent("brew", "ensure", "", time.Now(), err)
+		Expect(event.Result).To(Equal(printer.ResultError))
+		Expect(event.Errors).To(Equal([]string{"first", "second"}))
+	})
+
+	It("should fail for an unknown format", func() {
+		Expect(logger.SetFormat("yaml")).NotTo(Succeed())
+	})
+
+	It("should only print text for the text format", func() {
+		use(printer.FormatText)
+		logger.Note("hello")
+		logger.Event(printer.Event{Operation: "dump", Result: printer.ResultSuccess})
+		logger.Flush()
+
+		Expect(out.String()).To(ContainSubstring("hello"))
+		Expect(events.String()).To(BeEmpty())
+	})
+
+	It("should print each event on its own line for jsonl", func() {
+		use(printer.FormatJSONL)
+		logger.Event(printer.Event{Manager: "brew", Operation: "dump", Result: printer.ResultSuccess})
+		logger.Event(printer.Event{Operation: "dump", Result: printer.ResultError, Error: "failed"})
+
+		lines := strings.Split(strings.TrimSpace(events.String()), "\n")
+		Expect(lines).To(Equal([]string{
+			`{"manager":"brew","operation":"dump","result":"success"}`,
+			`{"operation":"dump","result":"error","error":"failed"}`,
+		}))
+	})
+
+	It("should print the events as an array when flushed for json", func() {
+		use(printer.FormatJSON)
+		logger.Note("hello")
+		logger.Event(printer.Event{Manager: "brew", Operation: "dump", Result: printer.ResultSuccess})
+		Expect(events.String()).To(BeEmpty())
+
+		logger.Flush()
+		var printed []printer.Event
+		Expect(json.Unmarshal(events.Bytes(), &printed)).To(Succeed())
+		Expect(printed).To(Equal([]printer.Event{{Manager: "brew", Operation: "dump", Result: printer.ResultSuccess}}))
+		Expect(out.String()).To(ContainSubstring("hello"))
+	})
+
+	It("should print an empty array if there are no events", func() {
+		use(printer.FormatJSON)
+		logger.Flush()
+
+		Expect(events.String()).To(Equal("[]\n"))
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/julienroland/usg"
@@ -32,9 +33,16 @@ var logLevels = map[string]logLevel{
 
 // Logger ...
 type Logger struct {
-	Out         io.Writer
+	Out io.Writer
+	// EventsOut is where events are printed for the json formats
+	EventsOut   io.Writer
+	format      string
+	events      []Event
 	logPrefixes map[string]string
 	timers      map[string]time.Time
+	// mutex keeps lines logged by managers running at the same time from
+	// being mixed
+	mutex *sync.Mutex
 }
 
 // New ...
@@ -47,8 +55,11 @@ func New() *Logger {
 
 	return &Logger{
 		Out:         os.Stdout,
+		EventsOut:   os.Stdout,
+		format:      FormatText,
 		logPrefixes: logPrefixes,
 		timers:      make(map[string]time.Time),
+		mutex:       new(sync.Mutex),
 	}
 }
 
@@ -86,6 +97,8 @@ func (logger Logger) log(label, msg string, args ...interface{}) {
 
 	logText := fmt.Sprintf(text, args...)
 
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
 	fmt.Fprintln(logger.Out, logger.logPrefixes[label]+logText)
 }
 
@@ -112,6 +125,9 @@ func (logger Logger) Note(msg string, args ...interface{}) {
 // Start ...
 func (logger Logger) Start(timer, msg string, args ...interface{}) {
 	logger.log("start", msg, args...)
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
 	logger.timers[timer] = time.Now()
 }
 
@@ -123,7 +139,9 @@ func (logger Logger) Progress(current, total int, msg string, args ...interface{
 
 // Done ...
 func (logger Logger) Done(timer, msg string, args ...interface{}) {
+	logger.mutex.Lock()
 	timeTaken := time.Since(logger.timers[timer])
+	logger.mutex.Unlock()
 
 	args = append(args, timeTaken)
 	logger.log("done", msg+" <reset><fg 0>(%s)<reset>", args...)
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// In is where the answers are read from, it can be replaced to answer
+	// without reading from stdin in tests.
+	In io.Reader = os.Stdin
+	// Out is where the questions are written
+	Out io.Writer = os.Stderr
+)
+
+// Confirm asks the user a yes or no question, anything but yes is a no.
+func Confirm(question string) bool {
+	fmt.Fprintf(Out, "%s [y/N] ", question)
+	answer := strings.ToLower(readLine())
+	return answer == "y" || answer == "yes"
+}
+
+// Pick lets the user choose any number of the options by their numbers,
+// returning the indices of the chosen ones. Unknown numbers are ignored.
+func Pick(question string, options []string) []int {
+	fmt.Fprintln(Out, question)
+	for i, option := range options {
+		fmt.Fprintf(Out, "  %d) %s\n", i+1, option)
+	}
+	fmt.Fprint(Out, "numbers separated by spaces, all or none: ")
+
+	answer := strings.ToLower(readLine())
+	var picked []int
+	if answer == "all" {
+		for i := range options {
+			picked = append(picked, i)
+		}
+
+		return picked
+	}
+
+	seen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(options) || seen[n-1] {
+			continue
+		}
+
+		seen[n-1] = true
+		picked = append(picked, n-1)
+	}
+
+	return picked
+}
+
+// readLine reads a single line from In, a byte at a time so that nothing
+// after the line is consumed.
+func readLine() string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := In.Read(b)
+		if n == 0 || err != nil || b[0] == '\n' {
+			break
+		}
+
+		line = append(line, b[0])
+	}
+
+	return strings.TrimSpace(string(line))
+}
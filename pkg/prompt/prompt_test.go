@@ -0,0 +1,70 @@
+package prompt_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/prompt"
+)
+
+func TestPrompt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Prompt Suite")
+}
+
+var _ = Describe("Prompt", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		prompt.Out = out
+	})
+
+	Context("Confirm", func() {
+		It("should be true when answering yes", func() {
+			prompt.In = strings.NewReader("Yes\n")
+			Expect(prompt.Confirm("sure?")).To(BeTrue())
+			Expect(out.String()).To(ContainSubstring("sure?"))
+		})
+
+		It("should be false for anything else", func() {
+			prompt.In = strings.NewReader("maybe\n")
+			Expect(prompt.Confirm("sure?")).To(BeFalse())
+		})
+
+		It("should only read a single line", func() {
+			prompt.In = strings.NewReader("y\nn\n")
+			Expect(prompt.Confirm("first?")).To(BeTrue())
+			Expect(prompt.Confirm("second?")).To(BeFalse())
+		})
+	})
+
+	Context("Pick", func() {
+		options := []string{"a", "b", "c"}
+
+		It("should list the options", func() {
+			prompt.In = strings.NewReader("\n")
+			Expect(prompt.Pick("which?", options)).To(BeEmpty())
+			Expect(out.String()).To(ContainSubstring("3) c"))
+		})
+
+		It("should return the picked options", func() {
+			prompt.In = strings.NewReader("3, 1\n")
+			Expect(prompt.Pick("which?", options)).To(Equal([]int{2, 0}))
+		})
+
+		It("should ignore unknown and repeated numbers", func() {
+			prompt.In = strings.NewReader("1 1 4 x\n")
+			Expect(prompt.Pick("which?", options)).To(Equal([]int{0}))
+		})
+
+		It("should pick everything for all", func() {
+			prompt.In = strings.NewReader("all\n")
+			Expect(prompt.Pick("which?", options)).To(Equal([]int{0, 1, 2}))
+		})
+	})
+})
@@ -0,0 +1,16 @@
+package run
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Hook runs the shell command configured as a hook, printing its output to
+// the user with each line prefixed if a prefix is given.
+func Hook(hook, prefix string) error {
+	err := ForUser(Commander("sh", "-c", hook), prefix)
+	if err != nil {
+		return errors.Wrapf(err, "hook %q failed", hook)
+	}
+
+	return nil
+}
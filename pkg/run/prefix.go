@@ -0,0 +1,91 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sync"
+)
+
+// lines keeps lines written through different Prefixed writers from being
+// mixed
+var lines sync.Mutex
+
+// Prefixed writes to Out line by line with each line prefixed, so that the
+// output of commands running at the same time can be told apart. Flush
+// should be called once the command has finished to write what remains of
+// the last line.
+type Prefixed struct {
+	prefix string
+	buf    []byte
+}
+
+// NewPrefixed creates a writer prefixing each line with the given prefix
+func NewPrefixed(prefix string) *Prefixed {
+	return &Prefixed{prefix: prefix}
+}
+
+// Write buffers the output until a full line has been written
+func (p *Prefixed) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+
+		err := p.writeLine(p.buf[:i])
+		p.buf = p.buf[i+1:]
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes the last line if it didn't end with a newline
+func (p *Prefixed) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	err := p.writeLine(p.buf)
+	p.buf = nil
+	return err
+}
+
+func (p *Prefixed) writeLine(line []byte) error {
+	lines.Lock()
+	defer lines.Unlock()
+
+	_, err := fmt.Fprintf(Out, "%s | %s\n", p.prefix, line)
+	return err
+}
+
+// Prefix returns what to prefix the output of the named manager with, which
+// is only done when managers run at the same time.
+func Prefix(name string, prefixed bool) string {
+	if !prefixed {
+		return ""
+	}
+
+	return name
+}
+
+// ForUser runs the command printing its output to the user, with each line
+// prefixed if a prefix is given.
+func ForUser(cmd *exec.Cmd, prefix string) error {
+	PrintToUser(cmd)
+	if prefix == "" {
+		return cmd.Run()
+	}
+
+	out := NewPrefixed(prefix)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+
+	return err
+}
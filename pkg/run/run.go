@@ -11,6 +11,13 @@ import (
 // want to mock how commands are run.
 var Commander = exec.Command
 
+// ReadOnly is used to create commands that only read, like listing what is
+// installed. It uses Commander unless replaced, which is done when doing a
+// dry run so that these commands still run.
+var ReadOnly = func(command string, args ...string) *exec.Cmd {
+	return Commander(command, args...)
+}
+
 // Out is the output to use when printing to the user. By default this is
 // os.Stdout but can be changed, e.g. when running tests that you don't
 // want printing output.
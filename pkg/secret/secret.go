@@ -0,0 +1,106 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/mbark/punkt/pkg/fs"
+)
+
+// ErrWrongPassphrase is returned if the secret can't be decrypted with the
+// given passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// Prompt asks the user for the passphrase, it can be replaced to avoid
+// reading from the terminal in tests.
+var Prompt = func() ([]byte, error) {
+	fmt.Fprint(os.Stderr, "passphrase: ")
+	defer fmt.Fprintln(os.Stderr)
+
+	return terminal.ReadPassword(int(os.Stdin.Fd()))
+}
+
+// Passphrase returns the passphrase to encrypt and decrypt secrets with,
+// read from the key file if one is given and otherwise asked for.
+func Passphrase(snapshot fs.Snapshot, keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		passphrase, err := Prompt()
+		return passphrase, errors.Wrapf(err, "failed to read the passphrase")
+	}
+
+	content, err := snapshot.Read(snapshot.ExpandHome(keyFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key file %s", keyFile)
+	}
+
+	return []byte(strings.TrimSpace(content)), nil
+}
+
+// Encrypt encrypts the content with the passphrase as an ASCII armored
+// OpenPGP message, so that it can also be decrypted using gpg.
+func Encrypt(content, passphrase []byte) ([]byte, error) {
+	var out bytes.Buffer
+	armored, err := armor.Encode(&out, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := openpgp.SymmetricallyEncrypt(armored, passphrase, nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encrypt")
+	}
+
+	if _, err = w.Write(content); err != nil {
+		return nil, errors.Wrapf(err, "failed to encrypt")
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, errors.Wrapf(err, "failed to encrypt")
+	}
+
+	if err = armored.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Decrypt decrypts content encrypted by Encrypt with the same passphrase.
+func Decrypt(content, passphrase []byte) ([]byte, error) {
+	block, err := armor.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "secret is not an armored message")
+	}
+
+	tried := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if tried {
+			return nil, ErrWrongPassphrase
+		}
+
+		tried = true
+		return passphrase, nil
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, nil, prompt, nil)
+	if err == ErrWrongPassphrase {
+		return nil, err
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt")
+	}
+
+	out, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt")
+	}
+
+	return out, nil
+}
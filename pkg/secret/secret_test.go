@@ -0,0 +1,81 @@
+package secret_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/mbark/punkt/pkg/fs"
+	"github.com/mbark/punkt/pkg/secret"
+	"github.com/mbark/punkt/testmock"
+)
+
+func TestSecret(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Secret Suite")
+}
+
+var _ = Describe("Secret", func() {
+	var snapshot fs.Snapshot
+
+	BeforeEach(func() {
+		snapshot, _ = testmock.Setup()
+		secret.Prompt = func() ([]byte, error) { return []byte("prompted"), nil }
+	})
+
+	Context("Encrypt", func() {
+		It("should be possible to decrypt with the same passphrase", func() {
+			encrypted, err := secret.Encrypt([]byte("machine example.com"), []byte("pass"))
+			Expect(err).To(BeNil())
+			Expect(string(encrypted)).NotTo(ContainSubstring("example.com"))
+
+			decrypted, err := secret.Decrypt(encrypted, []byte("pass"))
+			Expect(err).To(BeNil())
+			Expect(string(decrypted)).To(Equal("machine example.com"))
+		})
+	})
+
+	Context("Decrypt", func() {
+		It("should fail with the wrong passphrase", func() {
+			encrypted, err := secret.Encrypt([]byte("content"), []byte("pass"))
+			Expect(err).To(BeNil())
+
+			_, err = secret.Decrypt(encrypted, []byte("wrong"))
+			Expect(err).To(Equal(secret.ErrWrongPassphrase))
+		})
+
+		It("should fail if the content isn't encrypted", func() {
+			_, err := secret.Decrypt([]byte("content"), []byte("pass"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("Passphrase", func() {
+		It("should read the passphrase from the key file", func() {
+			Expect(snapshot.Save("from file\n", "/home/.punkt.key")).To(Succeed())
+
+			passphrase, err := secret.Passphrase(snapshot, "~/.punkt.key")
+			Expect(err).To(BeNil())
+			Expect(string(passphrase)).To(Equal("from file"))
+		})
+
+		It("should fail if the key file doesn't exist", func() {
+			_, err := secret.Passphrase(snapshot, "~/.punkt.key")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should ask for the passphrase without a key file", func() {
+			passphrase, err := secret.Passphrase(snapshot, "")
+			Expect(err).To(BeNil())
+			Expect(string(passphrase)).To(Equal("prompted"))
+		})
+
+		It("should fail if the passphrase can't be read", func() {
+			secret.Prompt = func() ([]byte, error) { return nil, errors.New("fail") }
+			_, err := secret.Passphrase(snapshot, "")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
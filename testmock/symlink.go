@@ -1,6 +1,7 @@
 package testmock
 
 import (
+	"github.com/mbark/punkt/pkg/drift"
 	"github.com/mbark/punkt/pkg/mgr/symlink"
 	"github.com/stretchr/testify/mock"
 )
@@ -28,6 +29,19 @@ func (m *LinkManager) Ensure(link *symlink.Symlink) error {
 	return args.Error(0)
 }
 
+// Status ...
+func (m *LinkManager) Status(link *symlink.Symlink) *drift.Drift {
+	args := m.Called(link)
+	d, _ := args.Get(0).(*drift.Drift)
+	return d
+}
+
+// Pull ...
+func (m *LinkManager) Pull(link *symlink.Symlink) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
 // Expand ...
 func (m *LinkManager) Expand(link symlink.Symlink) *symlink.Symlink {
 	return &link